@@ -0,0 +1,218 @@
+package account
+
+import (
+	"blog/auth"
+	"blog/cfg"
+	"blog/data"
+	"blog/logMnt"
+	"blog/mail"
+	"blog/token"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	store  *data.Store
+	mailer mail.Mailer
+)
+
+// 注入账号模块依赖的仓库和邮件发送器
+func Init(s *data.Store, m mail.Mailer) {
+	store = s
+	mailer = m
+}
+
+// 邮件中的链接：base_url + path?token=...
+func link(path, value string) string {
+	return strings.TrimRight(cfg.CFG.Account.BaseURL, "/") + path + "?token=" + url.QueryEscape(value)
+}
+
+// 向用户邮箱发送验证链接
+func SendVerificationMail(user *data.User) error {
+	value, err := signLink(purposeVerify, user)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Please confirm your email address by opening the link below:\n\n" +
+			link("/verify-email", value) + "\n\n" +
+			"The link expires in " + linkTTL(purposeVerify).String() + ". If you did not create an account, you can ignore this email.\n",
+	})
+}
+
+func sendResetMail(user *data.User) error {
+	value, err := signLink(purposeReset, user)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Someone requested a password reset for your account. Open the link below to choose a new password:\n\n" +
+			link("/reset-password", value) + "\n\n" +
+			"The link expires in " + linkTTL(purposeReset).String() + " and can be used only once. If you did not request a reset, you can ignore this email.\n",
+	})
+}
+
+// 重新发送邮箱验证邮件
+func SendVerification(c *gin.Context) {
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		auth.RespondError(c, err)
+		return
+	}
+
+	user, err := store.Users.FindByID(userID)
+	if err != nil {
+		c.Error(logMnt.ErrUserNotFound.Wrap(err))
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		logMnt.Success(c, http.StatusOK, gin.H{"email_verified_at": user.EmailVerifiedAt})
+		return
+	}
+
+	if err := SendVerificationMail(user); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("send verification email: %w", err)))
+		return
+	}
+	logMnt.Success(c, http.StatusOK, nil)
+}
+
+// 用邮件中的令牌验证邮箱
+func VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	user, err := parseLink(purposeVerify, req.Token)
+	if errors.Is(err, ErrInvalidLink) {
+		c.Error(logMnt.ErrInvalidVerificationLink.Wrap(err))
+		return
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("verify email: %w", err)))
+		return
+	}
+
+	if err := store.Users.MarkEmailVerified(user); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("verify email: %w", err)))
+		return
+	}
+
+	zap.L().Info("email verified", zap.Uint("user_id", user.ID))
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+}
+
+// 申请重置密码；无论邮箱是否注册都返回相同的结果，避免泄露注册信息
+func ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	user, err := store.Users.FindByEmail(req.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("request password reset: %w", err)))
+		return
+	}
+	if user != nil {
+		//异步发送，响应时间不因邮箱是否注册而不同
+		go func() {
+			if err := sendResetMail(user); err != nil {
+				zap.L().Error("failed to send password reset email", zap.Uint("user_id", user.ID), zap.Error(err))
+			}
+		}()
+	}
+
+	//无论邮箱是否注册都返回相同的结果
+	logMnt.Success(c, http.StatusOK, nil)
+}
+
+// 用邮件中的令牌设置新密码，并让该用户所有已登录的会话失效
+func ResetPassword(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	user, err := parseLink(purposeReset, req.Token)
+	if errors.Is(err, ErrInvalidLink) {
+		c.Error(logMnt.ErrInvalidResetLink.Wrap(err))
+		return
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("reset password: %w", err)))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err)))
+		return
+	}
+	if err := store.Users.UpdatePassword(user, string(hashedPassword)); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("reset password: %w", err)))
+		return
+	}
+	if err := token.RevokeUser(user.ID); err != nil {
+		zap.L().Warn("failed to revoke sessions after password reset", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+
+	zap.L().Info("password reset", zap.Uint("user_id", user.ID))
+	logMnt.Success(c, http.StatusOK, nil)
+}
+
+// 开启account.require_verified时，邮箱未验证的用户不能继续操作；需要放在认证中间件之后
+func RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.CFG.Account.RequireVerified {
+			c.Next()
+			return
+		}
+
+		userID, err := auth.CurrentUserID(c)
+		if err != nil {
+			auth.RespondError(c, err)
+			c.Abort()
+			return
+		}
+		user, err := store.Users.FindByID(userID)
+		if err != nil {
+			c.Error(logMnt.ErrUnauthorized.Wrap(err))
+			c.Abort()
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			c.Error(logMnt.ErrEmailNotVerified)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
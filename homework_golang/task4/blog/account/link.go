@@ -0,0 +1,119 @@
+package account
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 邮件链接令牌的用途
+const (
+	purposeVerify = "verify"
+	purposeReset  = "reset"
+)
+
+// 未配置时的链接有效期
+const (
+	defaultVerifyTTL = 48 * time.Hour
+	defaultResetTTL  = time.Hour
+)
+
+var ErrInvalidLink = errors.New("invalid link or link has expired")
+
+// 链接令牌中的声明，Fingerprint绑定签发时的邮箱或密码哈希：
+// 修改邮箱后旧的验证链接失效，密码重置成功后重置链接随即失效
+type linkClaims struct {
+	UserID      uint   `json:"uid"`
+	Purpose     string `json:"act"`
+	Fingerprint string `json:"fp"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+func linkSecret() []byte {
+	if cfg.CFG.Account.TokenSecret != "" {
+		return []byte(cfg.CFG.Account.TokenSecret)
+	}
+	return []byte(cfg.CFG.Jwt.Secret)
+}
+
+func linkTTL(purpose string) time.Duration {
+	if purpose == purposeVerify {
+		if cfg.CFG.Account.VerifyTTL > 0 {
+			return cfg.CFG.Account.VerifyTTL
+		}
+		return defaultVerifyTTL
+	}
+	if cfg.CFG.Account.ResetTTL > 0 {
+		return cfg.CFG.Account.ResetTTL
+	}
+	return defaultResetTTL
+}
+
+func fingerprint(purpose string, user *data.User) string {
+	value := user.Email
+	if purpose == purposeReset {
+		value = user.Password
+	}
+	sum := sha256.Sum256([]byte(purpose + ":" + value))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func mac(payload string) string {
+	h := hmac.New(sha256.New, linkSecret())
+	h.Write([]byte("blog-account:" + payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// 签发链接令牌：base64(声明).base64(HMAC-SHA256)
+func signLink(purpose string, user *data.User) (string, error) {
+	raw, err := json.Marshal(linkClaims{
+		UserID:      user.ID,
+		Purpose:     purpose,
+		Fingerprint: fingerprint(purpose, user),
+		ExpiresAt:   time.Now().Add(linkTTL(purpose)).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + mac(payload), nil
+}
+
+// 验证链接令牌的签名、用途和有效期，返回令牌对应的用户
+func parseLink(purpose, link string) (*data.User, error) {
+	payload, signature, ok := strings.Cut(link, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(mac(payload))) {
+		return nil, ErrInvalidLink
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+	var claims linkClaims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, ErrInvalidLink
+	}
+	if claims.Purpose != purpose || time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrInvalidLink
+	}
+
+	user, err := store.Users.FindByID(claims.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(claims.Fingerprint), []byte(fingerprint(purpose, user))) {
+		return nil, ErrInvalidLink
+	}
+	return user, nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"blog/data"
+	"blog/logMnt"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 认证中间件存放用户身份的上下文键
+const (
+	UserIDKey = "userID"
+	RoleKey   = "role"
+)
+
+// 角色权限缓存的有效期，修改数据库中的角色权限后最迟在此时间后生效
+const permissionTTL = time.Minute
+
+var (
+	ErrUnauthenticated = errors.New("request is not authenticated")
+	ErrNotOwner        = errors.New("user does not own this resource")
+	ErrForbidden       = errors.New("role lacks the required permission")
+)
+
+var store *data.Store
+
+// 注入权限模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 角色名到权限集合的缓存
+var permissions = struct {
+	sync.RWMutex
+	roles    map[string]map[string]bool
+	loadedAt time.Time
+}{}
+
+// 读取认证中间件写入上下文的用户ID
+func CurrentUserID(c *gin.Context) (uint, error) {
+	value, ok := c.Get(UserIDKey)
+	if !ok {
+		return 0, ErrUnauthenticated
+	}
+	userID, ok := value.(uint)
+	if !ok || userID == 0 {
+		return 0, ErrUnauthenticated
+	}
+	return userID, nil
+}
+
+// 当前用户ID，未登录时为0，用于公开接口中区分作者本人
+func ViewerID(c *gin.Context) uint {
+	userID, _ := CurrentUserID(c)
+	return userID
+}
+
+// 读取认证中间件写入上下文的角色
+func CurrentRole(c *gin.Context) string {
+	return c.GetString(RoleKey)
+}
+
+// 判断角色是否拥有权限
+func HasPermission(role, permission string) (bool, error) {
+	permissions.RLock()
+	fresh := permissions.roles != nil && time.Since(permissions.loadedAt) < permissionTTL
+	granted := permissions.roles[role][permission]
+	permissions.RUnlock()
+	if fresh {
+		return granted, nil
+	}
+
+	//缓存过期，重新从数据库加载
+	roles, err := store.Roles.List()
+	if err != nil {
+		return false, err
+	}
+	loaded := make(map[string]map[string]bool, len(roles))
+	for _, r := range roles {
+		set := make(map[string]bool, len(r.Permissions))
+		for _, p := range r.Permissions {
+			set[p.Name] = true
+		}
+		loaded[r.Name] = set
+	}
+
+	permissions.Lock()
+	permissions.roles = loaded
+	permissions.loadedAt = time.Now()
+	permissions.Unlock()
+
+	return loaded[role][permission], nil
+}
+
+// 检查当前用户能否对资源执行操作：拥有action:any权限，或是所有者且拥有action:own权限
+func Authorize(c *gin.Context, ownerID uint, action string) error {
+	userID, err := CurrentUserID(c)
+	if err != nil {
+		return err
+	}
+	role := CurrentRole(c)
+
+	anyGranted, err := HasPermission(role, action+":any")
+	if err != nil {
+		return err
+	}
+	if anyGranted {
+		return nil
+	}
+
+	if userID != ownerID {
+		return ErrNotOwner
+	}
+	ownGranted, err := HasPermission(role, action+":own")
+	if err != nil {
+		return err
+	}
+	if !ownGranted {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// 将Authorize返回的错误转换为AppError交给错误处理中间件
+func RespondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotOwner):
+		c.Error(logMnt.ErrNotOwner)
+	case errors.Is(err, ErrForbidden):
+		c.Error(logMnt.ErrForbidden)
+	case errors.Is(err, ErrUnauthenticated):
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+	default:
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("load permissions: %w", err)))
+	}
+}
+
+// 权限中间件：当前用户的角色必须拥有指定权限，需放在认证中间件之后
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := CurrentUserID(c); err != nil {
+			c.Error(logMnt.ErrUnauthorized.Wrap(err))
+			c.Abort()
+			return
+		}
+
+		granted, err := HasPermission(CurrentRole(c), permission)
+		if err != nil {
+			c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("load permissions: %w", err)))
+			c.Abort()
+			return
+		}
+		if !granted {
+			c.Error(logMnt.ErrForbidden.With("permission", permission).Wrap(fmt.Errorf("role %q lacks %s", CurrentRole(c), permission)))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
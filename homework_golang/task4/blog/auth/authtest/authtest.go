@@ -0,0 +1,44 @@
+package authtest
+
+import (
+	"blog/auth"
+	"blog/data"
+	"blog/logMnt"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 假的角色仓库，只有作者角色，拥有Permissions中的权限
+type Roles struct {
+	data.RoleRepo
+	Permissions []string
+}
+
+func (r Roles) List() ([]data.Role, error) {
+	role := data.Role{Name: data.RoleAuthor}
+	for _, name := range r.Permissions {
+		role.Permissions = append(role.Permissions, data.Permission{Name: name})
+	}
+	return []data.Role{role}, nil
+}
+
+// 以作者角色、userID的身份请求处理函数，userID为0时不带认证身份
+func Request(handler gin.HandlerFunc, method string, userID uint, body string) (int, logMnt.Response) {
+	r := gin.New()
+	r.Use(logMnt.ErrorHandlingMiddleware())
+	r.Handle(method, "/", func(c *gin.Context) {
+		if userID != 0 {
+			c.Set(auth.UserIDKey, userID)
+			c.Set(auth.RoleKey, data.RoleAuthor)
+		}
+	}, handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, "/", bytes.NewBufferString(body)))
+	var resp logMnt.Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
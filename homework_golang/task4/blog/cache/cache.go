@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"blog/cfg"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 未配置时缓存的条目数和有效期
+const (
+	defaultCapacity = 1000
+	defaultTTL      = 5 * time.Minute
+)
+
+// 缓存存储，默认保存在进程内存中，多实例部署时可替换为分布式缓存
+type Cache interface {
+	// 读取key对应的值，不存在或已过期时ok为false
+	Get(key string) (value []byte, ok bool, err error)
+	// 写入key对应的值，ttl后过期
+	Set(key string, value []byte, ttl time.Duration) error
+	// 删除指定的key
+	Delete(keys ...string) error
+	// 删除以prefix开头的全部key
+	DeletePrefix(prefix string) error
+}
+
+var store Cache = NewLRU(capacity())
+
+// 替换缓存存储
+func Init(c Cache) {
+	store = c
+}
+
+// 包初始化时调用，配置文件未加载（如在包目录下运行测试）时使用默认容量
+func capacity() int {
+	if cfg.CFG != nil && cfg.CFG.Cache.Capacity > 0 {
+		return cfg.CFG.Cache.Capacity
+	}
+	return defaultCapacity
+}
+
+func ttl() time.Duration {
+	if cfg.CFG.Cache.TTL > 0 {
+		return cfg.CFG.Cache.TTL
+	}
+	return defaultTTL
+}
+
+// 读取缓存，缓存不可用时按未命中处理
+func Get(key string) ([]byte, bool) {
+	if !cfg.CFG.Cache.Enabled {
+		return nil, false
+	}
+	value, ok, err := store.Get(key)
+	if err != nil {
+		zap.L().Warn("cache unavailable", zap.String("key", key), zap.Error(err))
+		return nil, false
+	}
+	return value, ok
+}
+
+// 写入缓存，缓存不可用时只记录日志
+func Set(key string, value []byte) {
+	if !cfg.CFG.Cache.Enabled {
+		return
+	}
+	if err := store.Set(key, value, ttl()); err != nil {
+		zap.L().Warn("cache unavailable", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// 文章详情和文章列表的缓存键
+const (
+	postPrefix     = "post:"
+	postListPrefix = "posts:"
+)
+
+// 文章详情的缓存键
+func PostKey(id uint) string {
+	return postPrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+// 文章列表的缓存键，query为规范化的查询参数；列表包含未发布的文章时结果因人而异，需要带上viewerID
+func PostListKey(viewerID uint, query string) string {
+	return postListPrefix + strconv.FormatUint(uint64(viewerID), 10) + "?" + query
+}
+
+// 文章变化后清除它们的详情缓存和全部列表缓存；ids为空时只清除列表缓存
+func InvalidatePosts(ids ...uint) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = PostKey(id)
+	}
+	if len(keys) > 0 {
+		if err := store.Delete(keys...); err != nil {
+			zap.L().Warn("failed to invalidate post cache", zap.Error(err))
+		}
+	}
+	if err := store.DeletePrefix(postListPrefix); err != nil {
+		zap.L().Warn("failed to invalidate post list cache", zap.Error(err))
+	}
+}
+
+// 标签、分类等被许多文章引用的数据变化后清除全部文章缓存
+func InvalidateAllPosts() {
+	for _, prefix := range []string{postPrefix, postListPrefix} {
+		if err := store.DeletePrefix(prefix); err != nil {
+			zap.L().Warn("failed to invalidate post cache", zap.Error(err))
+		}
+	}
+}
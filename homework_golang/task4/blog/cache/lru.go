@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// 进程内的LRU缓存，超过容量时淘汰最久没有读写的条目，过期的条目在读取时丢弃
+type lru struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List //最近使用的在前
+	entries  map[string]*list.Element
+}
+
+// 创建最多保存capacity个条目的进程内LRU缓存
+func NewLRU(capacity int) Cache {
+	return &lru{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+func (c *lru) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if !time.Now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (c *lru) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *lru) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+	}
+	return nil
+}
+
+func (c *lru) DeletePrefix(prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(elem)
+		}
+	}
+	return nil
+}
+
+func (c *lru) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*lruEntry).key)
+}
@@ -2,31 +2,161 @@ package cfg
 
 import (
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type ServerConfig struct {
-	Port uint `yaml:"port"`
+	Port           uint     `yaml:"port"`
+	TrustedProxies []string `yaml:"trusted_proxies"` //可信的反向代理地址或网段，只有来自这些地址的请求才采用X-Forwarded-For中的客户端IP
 }
 type DbConfig struct {
-	Type     string `yaml:"type"`
+	Type     string `yaml:"type"` //mysql、postgres或sqlite
 	Host     string `yaml:"host"`
 	Port     uint   `yaml:"port"`
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	Dbname   string `yaml:"dbname"`
-	Charset  string `yaml:"charset"`
+	Charset  string `yaml:"charset"` //mysql字符集
+	SSLMode  string `yaml:"sslmode"` //postgres连接的sslmode
+	Path     string `yaml:"path"`    //sqlite数据库文件路径，":memory:"表示内存数据库
+
+	//连接池配置
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	//服务启动时自动执行未执行的迁移（生产环境建议关闭，改用blog migrate up）
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// JWT签名密钥，Secret用于HS256，PrivateKeyFile/PublicKeyFile为RS256或EdDSA的PEM文件
+type JwtKeyConfig struct {
+	Kid            string     `yaml:"kid"`
+	Alg            string     `yaml:"alg"` //HS256、RS256或EdDSA
+	Secret         string     `yaml:"secret"`
+	PrivateKeyFile string     `yaml:"private_key_file"` //只用于验证的密钥可以不配置私钥
+	PublicKeyFile  string     `yaml:"public_key_file"`
+	RetiredAt      *time.Time `yaml:"retired_at"` //退役时间，宽限期过后不再接受该密钥签发的令牌
 }
 
 type JwtConfig struct {
-	Secret string `yaml:"secret"`
+	Secret      string         `yaml:"secret"` //未配置keys时用作HS256密钥；配置keys后在legacy_until之前仍可验证不带kid的旧令牌
+	Keys        []JwtKeyConfig `yaml:"keys"`
+	ActiveKid   string         `yaml:"active_kid"`   //签发新令牌使用的密钥
+	RetireGrace time.Duration  `yaml:"retire_grace"` //密钥退役后的宽限期，默认为访问令牌有效期
+	LegacyUntil *time.Time     `yaml:"legacy_until"` //配置keys后接受不带kid的旧令牌的截止时间，不配置时不再接受
+	AccessTTL   time.Duration  `yaml:"access_ttl"`   //访问令牌有效期
+	RefreshTTL  time.Duration  `yaml:"refresh_ttl"`  //刷新令牌有效期
+}
+
+type PostConfig struct {
+	PublishInterval   time.Duration `yaml:"publish_interval"`    //检查并发布到期定时文章的间隔
+	ViewFlushInterval time.Duration `yaml:"view_flush_interval"` //把内存中的浏览数写入数据库的间隔
+}
+
+type CommentConfig struct {
+	MaxDepth int `yaml:"max_depth"` //评论最多嵌套的层数，1表示不允许回复
+}
+
+// 登录失败锁定：连续失败达到次数后锁定，每次锁定时长翻倍
+type LoginConfig struct {
+	MaxFailures   int           `yaml:"max_failures"`    //同一用户名连续失败多少次后锁定
+	IPMaxFailures int           `yaml:"ip_max_failures"` //同一IP连续失败多少次后锁定
+	Lockout       time.Duration `yaml:"lockout"`         //首次锁定时长
+	MaxLockout    time.Duration `yaml:"max_lockout"`     //锁定时长上限
+	FailureWindow time.Duration `yaml:"failure_window"`  //超过该时间没有新的失败则重置计数
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     uint   `yaml:"port"` //默认587，服务器支持时使用STARTTLS
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type MailConfig struct {
+	Driver    string     `yaml:"driver"` //smtp或outbox
+	From      string     `yaml:"from"`
+	OutboxDir string     `yaml:"outbox_dir"` //outbox驱动写入邮件的目录
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+// 邮箱验证和密码重置
+type AccountConfig struct {
+	TokenSecret     string        `yaml:"token_secret"`     //签名邮件中链接令牌的密钥，默认使用jwt.secret
+	BaseURL         string        `yaml:"base_url"`         //邮件中链接指向的前端地址
+	VerifyTTL       time.Duration `yaml:"verify_ttl"`       //邮箱验证链接有效期
+	ResetTTL        time.Duration `yaml:"reset_ttl"`        //密码重置链接有效期
+	RequireVerified bool          `yaml:"require_verified"` //邮箱未验证的用户不能发表文章和评论
+}
+
+// 站点信息，用于订阅源和站点地图
+type SiteConfig struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	BaseURL     string `yaml:"base_url"`   //站点地址，文章链接为base_url/posts/文章ID
+	FeedLimit   int    `yaml:"feed_limit"` //订阅源中的文章数
+}
+
+// 静态站点导出
+type ExportConfig struct {
+	OutputDir   string `yaml:"output_dir"`   //导出目录
+	TemplateDir string `yaml:"template_dir"` //自定义模板目录，其中的同名模板覆盖内置模板
+	PageSize    int    `yaml:"page_size"`    //首页每页文章数
+}
+
+// 文件上传
+type MediaConfig struct {
+	Driver        string        `yaml:"driver"`         //存储方式，目前支持local
+	Dir           string        `yaml:"dir"`            //local存储保存文件的目录
+	MaxSize       int64         `yaml:"max_size"`       //单个文件的最大字节数
+	AllowedTypes  []string      `yaml:"allowed_types"`  //允许上传的文件类型，按内容检测
+	ThumbnailSize int           `yaml:"thumbnail_size"` //图片缩略图的最大宽高
+	OrphanTTL     time.Duration `yaml:"orphan_ttl"`     //未被文章引用的文件保留多久后清理
+}
+
+// 限流规则：每个key每period最多limit次请求，允许burst次突发
+type RateLimitRule struct {
+	Limit  int           `yaml:"limit"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"` //令牌桶容量，默认等于limit
+	Key    string        `yaml:"key"`   //ip或user，user在未登录时按ip计数
+}
+
+type CacheConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Capacity int           `yaml:"capacity"` //进程内缓存最多保存的条目数
+	TTL      time.Duration `yaml:"ttl"`      //缓存条目的有效期
+}
+
+// 接口消息的语言
+type I18nConfig struct {
+	DefaultLocale string `yaml:"default_locale"` //用户没有语言偏好且Accept-Language中没有支持的语言时使用，zh-CN或en
+}
+
+type RateLimitConfig struct {
+	Enabled bool                     `yaml:"enabled"`
+	Groups  map[string]RateLimitRule `yaml:"groups"` //按路由组配置，未配置的路由组不限流
 }
 
 type Config struct {
-	Server ServerConfig `yaml:"server"`
-	Db     DbConfig     `yaml:"db"`
-	Jwt    JwtConfig    `yaml:"jwt"`
+	Server    ServerConfig    `yaml:"server"`
+	Db        DbConfig        `yaml:"db"`
+	Jwt       JwtConfig       `yaml:"jwt"`
+	Post      PostConfig      `yaml:"post"`
+	Comment   CommentConfig   `yaml:"comment"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Login     LoginConfig     `yaml:"login"`
+	Mail      MailConfig      `yaml:"mail"`
+	Account   AccountConfig   `yaml:"account"`
+	Site      SiteConfig      `yaml:"site"`
+	Export    ExportConfig    `yaml:"export"`
+	Media     MediaConfig     `yaml:"media"`
+	Cache     CacheConfig     `yaml:"cache"`
+	I18n      I18nConfig      `yaml:"i18n"`
 }
 
 var CFG = loadConfig("cfg/config.yml")
@@ -1,8 +1,14 @@
 package comment
 
 import (
+	"blog/auth"
+	"blog/cfg"
 	"blog/data"
 	"blog/logMnt"
+	"blog/paging"
+	"blog/reaction"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -10,27 +16,65 @@ import (
 	"go.uber.org/zap"
 )
 
+// 未配置时评论最多嵌套的层数
+const defaultMaxDepth = 5
+
+var store *data.Store
+
+// 注入评论模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
 func CreateComment(c *gin.Context) {
 	//获取评论信息
 	var comment data.Comment
 	if err := c.ShouldBindJSON(&comment); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid create comment parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(logMnt.BindError(err))
 		return
 	}
 
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//评论者取自认证身份，忽略请求体中的user_id
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+		return
+	}
+	comment.UserID = userID
+	comment.Depth = 0
+
+	//回复评论：文章取自被回复的评论，层级不能超过配置的最大嵌套层数
+	if comment.ParentID != nil {
+		parent, err := store.Comments.FindByID(*comment.ParentID)
+		if err != nil {
+			c.Error(logMnt.ErrParentCommentNotFound)
+			return
+		}
+		if comment.PostID != 0 && comment.PostID != parent.PostID {
+			c.Error(logMnt.ErrParentCommentMismatch)
+			return
+		}
+		if parent.Depth+1 >= maxDepth() {
+			c.Error(logMnt.ErrReplyDepthExceeded)
+			return
+		}
+		comment.PostID = parent.PostID
+		comment.Depth = parent.Depth + 1
+	}
+
+	//检查评论的文章是否存在，只有已发布的文章可以评论
+	post, ok := findVisiblePost(c, comment.PostID)
+	if !ok {
+		return
+	}
+	if post.Status != data.PostPublished {
+		c.Error(logMnt.ErrCommentsClosed)
 		return
 	}
 
 	//插入评论信息
-	if err := db.Create(&comment).Error; err != nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to create comment"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+	if err = store.Comments.Create(&comment); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("create comment: %w", err)))
 		return
 	}
 
@@ -40,38 +84,170 @@ func CreateComment(c *gin.Context) {
 		zap.Time("created_at", comment.CreatedAt),
 	)
 	//返回发布评论成功的信息给客户端
-	c.JSON(http.StatusCreated, gin.H{
-		"message":    "Comment created successfully",
+	logMnt.Success(c, http.StatusCreated, gin.H{
 		"id":         comment.ID,
 		"content":    comment.Content,
+		"post_id":    comment.PostID,
+		"parent_id":  comment.ParentID,
+		"depth":      comment.Depth,
 		"created_at": comment.CreatedAt.Format(time.RFC3339),
 	})
 }
 
 func GetComments(c *gin.Context) {
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//获取分页、排序和过滤条件
+	query, err := paging.ParseQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if query.PostID == 0 {
+		c.Error(logMnt.InvalidField("post_id", "required", ""))
+		return
+	}
+	if _, ok := findVisiblePost(c, query.PostID); !ok {
 		return
 	}
 
-	//从数据表获取所有评论信息
-	var storedComments []data.Comment
-	if err := db.Find(&storedComments).Error; err != nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get comments"})
+	//从数据表获取一页评论信息
+	page, err := store.Comments.List(query)
+	if err != nil {
+		c.Error(paging.Error(fmt.Errorf("get comments: %w", err)))
+		return
+	}
+	if err := reaction.FillComments(page.Items, auth.ViewerID(c)); err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get comments: %w", err)))
 		return
 	}
 
 	zap.L().Info("get comments",
-		zap.Int("count", len(storedComments)),
+		zap.Int("count", len(page.Items)),
+		zap.Int64("total", page.Total),
 	)
-	//将所有评论信息发送给客户端
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Comment found successfully",
-		"count":   len(storedComments),
-		"data":    storedComments,
+	//将一页评论信息和翻页游标发送给客户端
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+		"items":       page.Items,
 	})
 }
+
+// 评论最多嵌套的层数
+func maxDepth() int {
+	if cfg.CFG.Comment.MaxDepth > 0 {
+		return cfg.CFG.Comment.MaxDepth
+	}
+	return defaultMaxDepth
+}
+
+func UpdateComment(c *gin.Context) {
+	//获取评论信息
+	var updateComment data.Comment
+	if err := c.ShouldBindJSON(&updateComment); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+	if updateComment.Content == "" {
+		c.Error(logMnt.InvalidField("content", "required", ""))
+		return
+	}
+
+	//查询评论并检查是否存在
+	comment, err := store.Comments.FindByID(updateComment.ID)
+	if err != nil {
+		c.Error(logMnt.ErrCommentNotFound)
+		return
+	}
+
+	//权限检查：评论者可修改自己的评论，编辑和管理员可修改任意评论
+	if err := auth.Authorize(c, comment.UserID, "comment:update"); err != nil {
+		auth.RespondError(c, err)
+		return
+	}
+
+	//更新评论内容并记录编辑时间
+	if err := store.Comments.Update(comment, updateComment.Content); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update comment: %w", err)))
+		return
+	}
+
+	zap.L().Info("update comment",
+		zap.Uint("comment_id", comment.ID),
+		zap.String("content", comment.Content),
+		zap.Time("edited_at", *comment.EditedAt),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"id":         comment.ID,
+		"content":    comment.Content,
+		"post_id":    comment.PostID,
+		"parent_id":  comment.ParentID,
+		"created_at": comment.CreatedAt.Format(time.RFC3339),
+		"edited_at":  comment.EditedAt.Format(time.RFC3339),
+	})
+}
+
+func DeleteComment(c *gin.Context) {
+	//获取评论信息
+	var deleteComment data.Comment
+	if err := c.ShouldBindJSON(&deleteComment); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	//查询评论并检查是否存在
+	comment, err := store.Comments.FindByID(deleteComment.ID)
+	if err != nil {
+		c.Error(logMnt.ErrCommentNotFound)
+		return
+	}
+
+	//权限检查：评论者、文章作者以及编辑和管理员可删除评论
+	if err := authorizeDelete(c, comment); err != nil {
+		auth.RespondError(c, err)
+		return
+	}
+
+	//软删除评论，评论树中保留占位
+	if err := store.Comments.Delete(comment); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("delete comment: %w", err)))
+		return
+	}
+
+	userID, _ := auth.CurrentUserID(c)
+	zap.L().Info("delete comment",
+		zap.Uint("comment_id", comment.ID),
+		zap.Uint("comment_user_id", comment.UserID),
+		zap.Uint("deleted_by", userID),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"id":         comment.ID,
+		"post_id":    comment.PostID,
+		"deleted_at": comment.DeletedAt.Time.Format(time.RFC3339),
+	})
+}
+
+// 查找当前用户可见的文章，文章不存在或未发布且不是作者本人时写入404响应并返回false
+func findVisiblePost(c *gin.Context, postID uint) (*data.Post, bool) {
+	post, err := store.Posts.FindByID(postID)
+	if err != nil || !post.VisibleTo(auth.ViewerID(c)) {
+		c.Error(logMnt.ErrPostNotFound)
+		return nil, false
+	}
+	return post, true
+}
+
+// 评论者或拥有comment:delete:any权限的用户可删除评论；文章作者也可删除自己文章下的评论
+func authorizeDelete(c *gin.Context, comment *data.Comment) error {
+	err := auth.Authorize(c, comment.UserID, "comment:delete")
+	if !errors.Is(err, auth.ErrNotOwner) {
+		return err
+	}
+
+	post, findErr := store.Posts.FindByID(comment.PostID)
+	if findErr != nil {
+		return err
+	}
+	return auth.Authorize(c, post.UserID, "comment:delete")
+}
@@ -0,0 +1,131 @@
+package comment
+
+import (
+	"blog/auth"
+	"blog/auth/authtest"
+	"blog/cfg"
+	"blog/data"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 测试用的假仓库，只实现处理函数用到的方法，其他方法调用时panic
+type fakeComments struct {
+	data.CommentRepo
+	comments map[uint]*data.Comment
+}
+
+func (f *fakeComments) FindByID(id uint) (*data.Comment, error) {
+	comment, ok := f.comments[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *comment
+	return &copied, nil
+}
+
+func (f *fakeComments) Create(comment *data.Comment) error {
+	comment.ID = uint(len(f.comments) + 1)
+	f.comments[comment.ID] = comment
+	return nil
+}
+
+func (f *fakeComments) Delete(comment *data.Comment) error {
+	delete(f.comments, comment.ID)
+	return nil
+}
+
+type fakePosts struct {
+	data.PostRepo
+	posts map[uint]*data.Post
+}
+
+func (f *fakePosts) FindByID(id uint) (*data.Post, error) {
+	post, ok := f.posts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return post, nil
+}
+
+// 用户1的文章下用户3发表的评论
+const (
+	postAuthorID = 1
+	commenterID  = 3
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	cfg.CFG = &cfg.Config{}
+	os.Exit(m.Run())
+}
+
+func setup(t *testing.T) *fakeComments {
+	t.Helper()
+	comments := &fakeComments{comments: map[uint]*data.Comment{
+		1: {Model: gorm.Model{ID: 1}, Content: "comment", PostID: 1, UserID: commenterID},
+	}}
+	posts := &fakePosts{posts: map[uint]*data.Post{
+		1: {Model: gorm.Model{ID: 1}, UserID: postAuthorID, Status: data.PostPublished},
+	}}
+	s := &data.Store{Comments: comments, Posts: posts, Roles: authtest.Roles{Permissions: []string{"comment:delete:own"}}}
+	Init(s)
+	auth.Init(s)
+	return comments
+}
+
+func TestDeleteCommentPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint
+		status int
+		code   string
+	}{
+		{"unauthenticated", 0, http.StatusUnauthorized, "unauthorized"},
+		{"non-owner", 2, http.StatusForbidden, "not_owner"},
+		{"commenter", commenterID, http.StatusOK, ""},
+		{"post author", postAuthorID, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments := setup(t)
+			status, resp := authtest.Request(DeleteComment, http.MethodDelete, tt.userID, `{"id":1}`)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			_, exists := comments.comments[1]
+			if tt.code != "" {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Fatalf("error = %+v, want code %q", resp.Error, tt.code)
+				}
+				if !exists {
+					t.Fatalf("comment was deleted by a caller without permission")
+				}
+				return
+			}
+			if !resp.Success || exists {
+				t.Fatalf("delete failed: %+v", resp)
+			}
+		})
+	}
+}
+
+// 请求体中的user_id被忽略，评论者取自认证身份
+func TestCreateCommentIgnoresUserID(t *testing.T) {
+	comments := setup(t)
+	status, resp := authtest.Request(CreateComment, http.MethodPost, commenterID, `{"post_id":1,"content":"reply","user_id":2}`)
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %+v", status, http.StatusCreated, resp.Error)
+	}
+	comment, ok := comments.comments[2]
+	if !ok {
+		t.Fatalf("comment was not created")
+	}
+	if comment.UserID != commenterID {
+		t.Fatalf("commenter = %d, want %d", comment.UserID, commenterID)
+	}
+}
@@ -0,0 +1,211 @@
+package comment
+
+import (
+	"blog/auth"
+	"blog/data"
+	"blog/logMnt"
+	"blog/paging"
+	"blog/reaction"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 每条评论下默认和最多展开的回复数
+const (
+	defaultReplyLimit = 3
+	maxReplyLimit     = 20
+)
+
+// 一次评论树请求最多返回的节点数，超过后不再展开更深的回复，可用replies_cursor或parent_id继续加载
+const maxTreeNodes = 1000
+
+// 已删除评论在评论树中显示的占位内容
+const deletedPlaceholder = "[deleted]"
+
+// 评论树中的一个节点
+type Node struct {
+	ID            uint       `json:"id"`
+	Content       string     `json:"content"`
+	UserID        uint       `json:"user_id"`
+	PostID        uint       `json:"post_id"`
+	ParentID      *uint      `json:"parent_id"`
+	Depth         int        `json:"depth"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	EditedAt      *time.Time `json:"edited_at"`
+	Deleted       bool       `json:"deleted"`
+	ReplyCount    int        `json:"reply_count"`
+	Replies       []*Node    `json:"replies"`
+	RepliesCursor string     `json:"replies_cursor"` //还有未展开的回复时，用于继续加载回复的游标
+
+	Reactions map[string]int64 `json:"reactions"`         //每种表态的数量
+	Reacted   []string         `json:"reacted,omitempty"` //当前用户做过的表态
+}
+
+func newNode(comment *data.Comment) *Node {
+	node := &Node{
+		ID:        comment.ID,
+		Content:   comment.Content,
+		UserID:    comment.UserID,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		Depth:     comment.Depth,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+		EditedAt:  comment.EditedAt,
+		Replies:   []*Node{},
+	}
+
+	//已删除的评论只保留位置，不返回内容和评论者
+	if comment.DeletedAt.Valid {
+		node.Content = deletedPlaceholder
+		node.UserID = 0
+		node.EditedAt = nil
+		node.Deleted = true
+	}
+	return node
+}
+
+// 读取某篇文章的评论树：
+// 顶层评论按分页参数分页，每条评论展开前reply_limit条回复，并逐层向下展开；
+// 传parent_id时返回该评论的回复（默认按时间正序），配合节点的replies_cursor加载更多回复
+func GetCommentTree(c *gin.Context) {
+	//获取分页、排序和过滤条件
+	query, err := paging.ParseQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if query.PostID == 0 {
+		c.Error(logMnt.InvalidField("post_id", "required", ""))
+		return
+	}
+	if _, ok := findVisiblePost(c, query.PostID); !ok {
+		return
+	}
+	if query.ParentID == 0 {
+		query.RootOnly = true
+	} else if c.Query("order") == "" {
+		query.Desc = false
+	}
+	//已删除的评论以占位形式保留，避免其回复脱离上下文
+	query.Deleted = true
+
+	replyLimit, err := strconv.Atoi(c.DefaultQuery("reply_limit", strconv.Itoa(defaultReplyLimit)))
+	if err != nil || replyLimit < 1 {
+		c.Error(logMnt.InvalidField("reply_limit", "min", "1"))
+		return
+	}
+	replyLimit = min(replyLimit, maxReplyLimit)
+
+	//获取本页评论
+	page, err := store.Comments.List(query)
+	if err != nil {
+		c.Error(paging.Error(fmt.Errorf("get comments: %w", err)))
+		return
+	}
+
+	roots := make([]*Node, len(page.Items))
+	for i := range page.Items {
+		roots[i] = newNode(&page.Items[i])
+	}
+
+	//逐层展开回复
+	nodes, err := expandReplies(roots, replyLimit)
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get comments: %w", err)))
+		return
+	}
+	if err := fillReactions(nodes, auth.ViewerID(c)); err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get comments: %w", err)))
+		return
+	}
+
+	zap.L().Info("get comment tree",
+		zap.Uint("post_id", query.PostID),
+		zap.Uint("parent_id", query.ParentID),
+		zap.Int("count", len(roots)),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"count":       len(roots),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+		"items":       roots,
+	})
+}
+
+// 每层用一次查询统计上一层节点的回复数，再用一次查询取出每个节点的前limit条回复挂上，返回树中的全部节点；
+// 节点总数达到maxTreeNodes后停止展开
+func expandReplies(level []*Node, limit int) ([]*Node, error) {
+	var nodes []*Node
+	for len(level) > 0 {
+		nodes = append(nodes, level...)
+		ids := make([]uint, len(level))
+		byID := make(map[uint]*Node, len(level))
+		for i, node := range level {
+			ids[i] = node.ID
+			byID[node.ID] = node
+		}
+
+		counts, err := store.Comments.CountReplies(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range level {
+			node.ReplyCount = counts[node.ID]
+		}
+		budget := maxTreeNodes - len(nodes)
+		if budget <= 0 {
+			break
+		}
+		replies, err := store.Comments.ListReplies(ids, limit)
+		if err != nil {
+			return nil, err
+		}
+		replies = replies[:min(len(replies), budget)]
+
+		var next []*Node
+		for i := range replies {
+			parent := byID[*replies[i].ParentID]
+			child := newNode(&replies[i])
+			parent.Replies = append(parent.Replies, child)
+			next = append(next, child)
+		}
+
+		//还有未展开的回复时，从最后一条已展开的回复之后继续加载
+		for _, node := range level {
+			if node.ReplyCount > len(node.Replies) && len(node.Replies) > 0 {
+				last := node.Replies[len(node.Replies)-1]
+				node.RepliesCursor = data.CursorAfter("created_at", false, last.CreatedAt.Format(time.RFC3339Nano), last.ID)
+			}
+		}
+		level = next
+	}
+	return nodes, nil
+}
+
+// 用一次查询为树中的全部节点填充表态
+func fillReactions(nodes []*Node, viewerID uint) error {
+	ids := make([]uint, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	counts, reacted, err := reaction.Load(data.ReactionComment, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		node.Reactions = counts[node.ID]
+		if node.Reactions == nil {
+			node.Reactions = map[string]int64{}
+		}
+		node.Reacted = reacted[node.ID]
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 订阅源和站点地图的内容：文章发布、修改、撤回和删除，标签修改和删除
+const ChangeFeed = "feed"
+
+// 一类内容最近一次变化的时间，用于无法由文章更新时间得出的Last-Modified，如文章撤回、删除和标签改名
+type SiteChange struct {
+	Name      string    `gorm:"primaryKey;size:64"`
+	ChangedAt time.Time `gorm:"not null"`
+}
+
+// 内容变化时间仓库
+type ChangeRepo interface {
+	Touch(name string, at time.Time) error
+	LastChanged(name string) (time.Time, error)
+}
+
+type changeRepo struct {
+	db *gorm.DB
+}
+
+// 记录name在at发生了变化
+func (r *changeRepo) Touch(name string, at time.Time) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"changed_at"}),
+	}).Create(&SiteChange{Name: name, ChangedAt: at}).Error
+}
+
+// name最近一次变化的时间，从未记录时返回零值
+func (r *changeRepo) LastChanged(name string) (time.Time, error) {
+	var change SiteChange
+	err := r.db.Where("name = ?", name).First(&change).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	return change.ChangedAt, err
+}
@@ -2,17 +2,20 @@ package data
 
 import (
 	"blog/cfg"
-	"fmt"
+	"time"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 type User struct {
 	gorm.Model
-	Username string `gorm:"unique;not null" json:"username" url:"username" form:"username"`
-	Password string `gorm:"not null" json:"password" url:"password" form:"password"`
-	Email    string `gorm:"unique;not null" json:"email" url:"email" form:"email"`
+	Username        string     `gorm:"unique;not null" json:"username" url:"username" form:"username"`
+	Password        string     `gorm:"not null" json:"password" url:"password" form:"password"`
+	Email           string     `gorm:"unique;not null" json:"email" url:"email" form:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	RoleID          uint       `gorm:"not null" json:"-"`
+	Role            *Role      `json:"role,omitempty"`
+	Locale          string     `gorm:"size:16;not null;default:''" json:"locale"` //接口消息的语言偏好，为空时按Accept-Language
 }
 
 type Post struct {
@@ -20,38 +23,86 @@ type Post struct {
 	Title   string `gorm:"not null" json:"title" url:"title" form:"title"`
 	Content string `gorm:"not null" json:"content" url:"content" form:"content"`
 	UserID  uint   `gorm:"not 0" json:"user_id" url:"user_id" form:"user_id"`
+
+	Format      string `gorm:"size:16;not null;default:markdown" json:"format"` //内容格式：markdown或plain
+	HTML        string `json:"html"`                                            //由内容渲染的HTML缓存
+	HTMLVersion int    `gorm:"not null;default:0" json:"-"`                     //渲染HTML时的渲染规则版本，与当前版本不同时重新渲染
+
+	Status    string     `gorm:"size:16;not null;default:published;index" json:"status"`
+	PublishAt *time.Time `gorm:"index" json:"publish_at"` //定时发布的时间，发布后为实际发布时间
+
+	Tags       []Tag      `gorm:"many2many:post_tags" json:"tags"`
+	Categories []Category `gorm:"many2many:post_categories" json:"categories"`
+	Media      []Media    `gorm:"many2many:post_media" json:"media,omitempty"`
+
+	ViewCount int64            `gorm:"not null;default:0" json:"view_count"`
+	Reactions map[string]int64 `gorm:"-" json:"reactions"`         //每种表态的数量
+	Reacted   []string         `gorm:"-" json:"reacted,omitempty"` //当前用户做过的表态
+}
+
+// 文章状态：草稿和定时发布的文章只有作者可见，归档的文章不出现在列表中
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+	PostArchived  = "archived"
+)
+
+// 文章对指定用户是否可见，userID为0表示未登录
+func (p *Post) VisibleTo(userID uint) bool {
+	return p.Status == PostPublished || p.Status == PostArchived || (userID != 0 && p.UserID == userID)
 }
 
 type Comment struct {
 	gorm.Model
-	Content string `gorm:"not null" json:"content" url:"content" form:"content"`
-	UserID  uint   `gorm:"not 0" json:"user_id" url:"user_id" form:"user_id"`
-	PostID  uint   `gorm:"not 0" json:"post_id" url:"post_id" form:"post_id"`
+	Content  string     `gorm:"not null" json:"content" url:"content" form:"content"`
+	UserID   uint       `gorm:"not 0" json:"user_id" url:"user_id" form:"user_id"`
+	PostID   uint       `gorm:"not 0" json:"post_id" url:"post_id" form:"post_id"`
+	ParentID *uint      `gorm:"index" json:"parent_id" url:"parent_id" form:"parent_id"`
+	Depth    int        `gorm:"not null;default:0" json:"depth"`
+	EditedAt *time.Time `json:"edited_at"`
+
+	Reactions map[string]int64 `gorm:"-" json:"reactions"`         //每种表态的数量
+	Reacted   []string         `gorm:"-" json:"reacted,omitempty"` //当前用户做过的表态
 }
 
-// 数据库连接和建表
-func ConnectDatabase() *gorm.DB {
-	//连接数据库
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=true&loc=Local",
-		cfg.CFG.Db.User,
-		cfg.CFG.Db.Password,
-		cfg.CFG.Db.Host,
-		cfg.CFG.Db.Port,
-		cfg.CFG.Db.Dbname,
-		cfg.CFG.Db.Charset,
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// 数据库连接，进程启动时调用一次（表结构由migration包管理），返回的连接池在整个应用内共享
+func InitDatabase(c cfg.DbConfig) (*gorm.DB, error) {
+	//按配置的数据库类型选择驱动
+	dialector, err := openDialector(c)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	//自动迁移（无表则建表，有表则更新结构）
-	if err = db.AutoMigrate(&User{}, &Post{}, &Comment{}); err != nil {
-		panic(err.Error())
-		return nil
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	//配置连接池
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if c.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+	//sqlite内存数据库随连接关闭而销毁，固定使用同一个连接
+	if isSqliteMemory(c) {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
 	}
 
-	return db
+	return db, nil
 }
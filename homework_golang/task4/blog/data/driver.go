@@ -0,0 +1,128 @@
+package data
+
+import (
+	"blog/cfg"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// sqlite内存数据库的路径
+const sqliteMemory = ":memory:"
+
+// 数据库驱动：校验配置、拼接DSN并生成gorm方言
+type driver struct {
+	validate func(c cfg.DbConfig) error
+	dsn      func(c cfg.DbConfig) string
+	open     func(dsn string) gorm.Dialector
+}
+
+// 按DbConfig.Type注册的驱动
+var drivers = map[string]driver{
+	"mysql": {
+		validate: validateServer,
+		dsn:      mysqlDSN,
+		open:     mysql.Open,
+	},
+	"postgres": {
+		validate: validateServer,
+		dsn:      postgresDSN,
+		open:     postgres.Open,
+	},
+	"sqlite": {
+		validate: validateSqlite,
+		dsn:      sqliteDSN,
+		open:     sqlite.Open,
+	},
+}
+
+// 根据配置选择驱动并生成gorm方言
+func openDialector(c cfg.DbConfig) (gorm.Dialector, error) {
+	dbType := strings.ToLower(c.Type)
+	if dbType == "" {
+		dbType = "mysql"
+	}
+
+	d, ok := drivers[dbType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type %q", c.Type)
+	}
+	if err := d.validate(c); err != nil {
+		return nil, fmt.Errorf("invalid %s config: %w", dbType, err)
+	}
+
+	return d.open(d.dsn(c)), nil
+}
+
+// 是否为sqlite内存数据库（每个连接各自独立，连接池只能保留一个连接）
+func isSqliteMemory(c cfg.DbConfig) bool {
+	return strings.ToLower(c.Type) == "sqlite" && c.Path == sqliteMemory
+}
+
+// mysql和postgres需要连接数据库服务
+func validateServer(c cfg.DbConfig) error {
+	switch {
+	case c.Host == "":
+		return errors.New("host is required")
+	case c.Port == 0:
+		return errors.New("port is required")
+	case c.User == "":
+		return errors.New("user is required")
+	case c.Dbname == "":
+		return errors.New("dbname is required")
+	}
+	return nil
+}
+
+func validateSqlite(c cfg.DbConfig) error {
+	if c.Path == "" {
+		return errors.New("path is required, use \":memory:\" for an in-memory database")
+	}
+	return nil
+}
+
+func mysqlDSN(c cfg.DbConfig) string {
+	charset := c.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=true&loc=Local",
+		c.User,
+		c.Password,
+		c.Host,
+		c.Port,
+		c.Dbname,
+		charset,
+	)
+}
+
+func postgresDSN(c cfg.DbConfig) string {
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.Host,
+		c.Port,
+		c.User,
+		c.Password,
+		c.Dbname,
+		sslMode,
+	)
+}
+
+func sqliteDSN(c cfg.DbConfig) string {
+	//开启外键约束，并在写锁冲突时等待而不是立即报错
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if c.Path == sqliteMemory {
+		return sqliteMemory + "?" + pragmas
+	}
+	return "file:" + c.Path + "?" + pragmas
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 登录记录，成功和失败的登录都会记录；用户名不存在时UserID为空
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"-"`
+	Username  string    `gorm:"size:64;not null" json:"-"`
+	IP        string    `gorm:"size:64;not null;index" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"size:32" json:"reason,omitempty"` //失败原因：password、locked
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 按用户名或IP统计的连续登录失败，Subject形如user:1、username:alice、ip:127.0.0.1
+type LoginFailure struct {
+	Subject      string `gorm:"primaryKey;size:191"`
+	Failures     int    `gorm:"not null"` //本轮连续失败次数
+	Lockouts     int    `gorm:"not null"` //已锁定次数，决定下一次锁定时长
+	LockedUntil  *time.Time
+	LastFailedAt time.Time `gorm:"not null;index"`
+}
+
+// 登录记录仓库
+type LoginRepo interface {
+	CreateAttempt(attempt *LoginAttempt) error
+	ListAttempts(userID uint, limit int) ([]LoginAttempt, error)
+	FindFailures(subjects ...string) ([]LoginFailure, error)
+	RecordFailure(subject string, apply func(failure *LoginFailure)) (*LoginFailure, error)
+	ClearFailure(subject string) error
+	PurgeFailures(before time.Time) error
+}
+
+type loginRepo struct {
+	db *gorm.DB
+}
+
+func (r *loginRepo) CreateAttempt(attempt *LoginAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// 用户最近的登录记录，按时间倒序
+func (r *loginRepo) ListAttempts(userID uint, limit int) ([]LoginAttempt, error) {
+	var attempts []LoginAttempt
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+func (r *loginRepo) FindFailures(subjects ...string) ([]LoginFailure, error) {
+	var failures []LoginFailure
+	if err := r.db.Where("subject IN ?", subjects).Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// 在事务中锁定并读取失败计数，由apply更新后写回；先插入空记录保证有行可锁，并发的失败不会互相覆盖
+func (r *loginRepo) RecordFailure(subject string, apply func(failure *LoginFailure)) (*LoginFailure, error) {
+	var failure LoginFailure
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		empty := LoginFailure{Subject: subject, LastFailedAt: time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&empty).Error; err != nil {
+			return err
+		}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("subject = ?", subject).First(&failure).Error
+		if err != nil {
+			return err
+		}
+		apply(&failure)
+		return tx.Save(&failure).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &failure, nil
+}
+
+func (r *loginRepo) ClearFailure(subject string) error {
+	return r.db.Where("subject = ?", subject).Delete(&LoginFailure{}).Error
+}
+
+// 清理长时间没有新失败且已解除锁定的计数
+func (r *loginRepo) PurgeFailures(before time.Time) error {
+	return r.db.Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&LoginFailure{}).Error
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 上传的文件，按内容的SHA-256去重，相同内容只保存一份
+type Media struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Hash         string    `gorm:"size:64;unique;not null" json:"hash"`
+	Key          string    `gorm:"not null" json:"-"` //文件在存储中的键
+	ThumbnailKey string    `json:"-"`                 //缩略图在存储中的键，不是图片时为空
+	Filename     string    `gorm:"not null" json:"filename"`
+	ContentType  string    `gorm:"size:128;not null" json:"content_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"` //第一个上传者
+	CreatedAt    time.Time `json:"created_at"`
+
+	URL          string `gorm:"-" json:"url"`
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url,omitempty"`
+}
+
+// 读取和保存后填充文件的访问地址
+func (m *Media) AfterFind(tx *gorm.DB) error {
+	m.setURLs()
+	return nil
+}
+
+func (m *Media) AfterCreate(tx *gorm.DB) error {
+	m.setURLs()
+	return nil
+}
+
+func (m *Media) setURLs() {
+	m.URL = "/media/" + m.Hash
+	if m.ThumbnailKey != "" {
+		m.ThumbnailURL = m.URL + "/thumbnail"
+	}
+}
+
+// 媒体仓库
+type MediaRepo interface {
+	Create(media *Media) error
+	FindByHash(hash string) (*Media, error)
+	FindByIDs(ids []uint) ([]Media, error)
+	ListOrphans(before time.Time, limit int) ([]Media, error)
+	Delete(media *Media, remove func()) error
+}
+
+type mediaRepo struct {
+	db *gorm.DB
+}
+
+func (r *mediaRepo) Create(media *Media) error {
+	return r.db.Create(media).Error
+}
+
+func (r *mediaRepo) FindByHash(hash string) (*Media, error) {
+	var media Media
+	if err := r.db.Where("hash = ?", hash).First(&media).Error; err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+func (r *mediaRepo) FindByIDs(ids []uint) ([]Media, error) {
+	var media []Media
+	if err := r.db.Where("id IN ?", ids).Find(&media).Error; err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// 在before之前上传且没有被任何文章引用的文件
+func (r *mediaRepo) ListOrphans(before time.Time, limit int) ([]Media, error) {
+	var media []Media
+	err := r.db.Where("created_at < ?", before).
+		Where("id NOT IN (?)", r.db.Table("post_media").Select("media_id")).
+		Order("id").
+		Limit(limit).
+		Find(&media).Error
+	if err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// 在事务中锁定并删除文件记录，提交前调用remove删除存储中的文件；仍被文章引用或已被删除时返回gorm.ErrRecordNotFound。
+// 同一内容的并发上传插入记录时会等待事务结束，之后写入的文件不会被remove删除
+func (r *mediaRepo) Delete(media *Media, remove func()) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var locked Media
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND id NOT IN (?)", media.ID, tx.Table("post_media").Select("media_id")).
+			First(&locked).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&locked).Error; err != nil {
+			return err
+		}
+		remove()
+		return nil
+	})
+}
@@ -0,0 +1,221 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 列表默认和最大分页大小
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("unsupported sort field")
+)
+
+// 列表查询条件，零值字段表示不过滤
+type ListQuery struct {
+	Limit      int
+	Cursor     string
+	Sort       string //created_at、updated_at或title
+	Desc       bool
+	AuthorID   uint
+	PostID     uint   //只用于评论列表
+	ParentID   uint   //只用于评论列表
+	TagID      uint   //只查询带该标签的文章
+	CategoryID uint   //只查询该分类及其子分类下的文章
+	Status     string //文章状态，为空时只查询已发布的文章，all表示全部状态
+	ViewerID   uint   //当前用户，未发布的文章只对作者可见
+	RootOnly   bool   //只查询顶层评论
+	Deleted    bool   //包含已软删除的记录
+	From       *time.Time
+	To         *time.Time
+}
+
+// 游标分页结果
+type Page[T any] struct {
+	Items      []T
+	Total      int64
+	NextCursor string
+	PrevCursor string
+}
+
+// 游标内容：上一页最后一条（或下一页第一条）记录的排序值和ID
+type cursor struct {
+	Sort   string `json:"s"`
+	Desc   bool   `json:"d"`
+	Value  string `json:"v"`
+	ID     uint   `json:"id"`
+	Before bool   `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// 生成从指定记录之后继续翻页的游标，value为记录的排序值
+func CursorAfter(sort string, desc bool, value string, id uint) string {
+	return cursor{Sort: sort, Desc: desc, Value: value, ID: id}.encode()
+}
+
+// 可排序字段：列名以及游标值与数据库值之间的转换
+type sortField struct {
+	column string
+	value  func(s string) (interface{}, error)
+}
+
+func timeSortField(column string) sortField {
+	return sortField{column: column, value: func(s string) (interface{}, error) {
+		return time.Parse(time.RFC3339Nano, s)
+	}}
+}
+
+func stringSortField(column string) sortField {
+	return sortField{column: column, value: func(s string) (interface{}, error) {
+		return s, nil
+	}}
+}
+
+// 按排序字段和ID做键集分页，key返回记录的排序值（已格式化为字符串）和ID
+func paginate[T any](db *gorm.DB, q ListQuery, fields map[string]sortField, key func(item *T, sort string) (string, uint)) (*Page[T], error) {
+	if q.Sort == "" {
+		q.Sort = "created_at"
+	}
+	field, ok := fields[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrInvalidSort, q.Sort)
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+
+	var page Page[T]
+	if err := db.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	//解析游标，游标必须与当前排序方式一致
+	tx := db.Session(&gorm.Session{})
+	var cur *cursor
+	if q.Cursor != "" {
+		var err error
+		if cur, err = decodeCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+		if cur.Sort != q.Sort || cur.Desc != q.Desc {
+			return nil, ErrInvalidCursor
+		}
+		value, err := field.value(cur.Value)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+
+		//向后翻页取排序方向上更靠后的记录，向前翻页相反
+		op := ">"
+		if q.Desc != cur.Before {
+			op = "<"
+		}
+		tx = tx.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", field.column, op, field.column, op),
+			value, value, cur.ID,
+		)
+	}
+
+	//向前翻页时反向排序，取出后再翻转
+	desc := q.Desc
+	before := cur != nil && cur.Before
+	if before {
+		desc = !desc
+	}
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	var items []T
+	err := tx.Order(fmt.Sprintf("%s %s, id %s", field.column, direction, direction)).
+		Limit(q.Limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(items) > q.Limit
+	if hasMore {
+		items = items[:q.Limit]
+	}
+	if before {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	page.Items = items
+
+	if len(items) == 0 {
+		return &page, nil
+	}
+
+	//有更多记录的方向生成游标
+	if (!before && hasMore) || (before && cur != nil) {
+		value, id := key(&items[len(items)-1], q.Sort)
+		page.NextCursor = cursor{Sort: q.Sort, Desc: q.Desc, Value: value, ID: id}.encode()
+	}
+	if (before && hasMore) || (!before && cur != nil) {
+		value, id := key(&items[0], q.Sort)
+		page.PrevCursor = cursor{Sort: q.Sort, Desc: q.Desc, Value: value, ID: id, Before: true}.encode()
+	}
+	return &page, nil
+}
+
+// 应用作者和时间范围过滤，文章和评论共用
+func applyFilters(db *gorm.DB, q ListQuery) *gorm.DB {
+	if q.AuthorID != 0 {
+		db = db.Where("user_id = ?", q.AuthorID)
+	}
+	if q.From != nil {
+		db = db.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where("created_at < ?", *q.To)
+	}
+	return db
+}
+
+// 应用评论特有的文章和上级评论过滤，文章表没有这些列
+func applyCommentFilters(db *gorm.DB, q ListQuery) *gorm.DB {
+	db = applyFilters(db, q)
+	if q.PostID != 0 {
+		db = db.Where("post_id = ?", q.PostID)
+	}
+	if q.ParentID != 0 {
+		db = db.Where("parent_id = ?", q.ParentID)
+	}
+	if q.RootOnly {
+		db = db.Where("parent_id IS NULL")
+	}
+	return db
+}
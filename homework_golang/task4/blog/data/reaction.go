@@ -0,0 +1,103 @@
+package data
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 可以表态的对象类型
+const (
+	ReactionPost    = "post"
+	ReactionComment = "comment"
+)
+
+// 用户对文章或评论的表态，每个用户对同一对象的每种表态只能有一个
+type Reaction struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	TargetType string    `gorm:"size:16;not null;uniqueIndex:idx_reactions_unique,priority:1" json:"target_type"`
+	TargetID   uint      `gorm:"not null;uniqueIndex:idx_reactions_unique,priority:2" json:"target_id"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_reactions_unique,priority:3" json:"user_id"`
+	Kind       string    `gorm:"size:16;not null;uniqueIndex:idx_reactions_unique,priority:4" json:"kind"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// 表态仓库
+type ReactionRepo interface {
+	Add(reaction *Reaction) (bool, error)
+	Remove(reaction *Reaction) (bool, error)
+	Counts(targetType string, ids []uint) (map[uint]map[string]int64, error)
+	ByUser(userID uint, targetType string, ids []uint) (map[uint][]string, error)
+}
+
+type reactionRepo struct {
+	db *gorm.DB
+}
+
+// 添加表态，已经存在时不重复添加并返回false
+func (r *reactionRepo) Add(reaction *Reaction) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// 取消表态，不存在时返回false
+func (r *reactionRepo) Remove(reaction *Reaction) (bool, error) {
+	result := r.db.
+		Where("target_type = ? AND target_id = ? AND user_id = ? AND kind = ?", reaction.TargetType, reaction.TargetID, reaction.UserID, reaction.Kind).
+		Delete(&Reaction{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// 一批对象每种表态的数量
+func (r *reactionRepo) Counts(targetType string, ids []uint) (map[uint]map[string]int64, error) {
+	counts := map[uint]map[string]int64{}
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		TargetID uint
+		Kind     string
+		Count    int64
+	}
+	err := r.db.Model(&Reaction{}).
+		Select("target_id, kind, COUNT(*) AS count").
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Group("target_id, kind").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if counts[row.TargetID] == nil {
+			counts[row.TargetID] = map[string]int64{}
+		}
+		counts[row.TargetID][row.Kind] = row.Count
+	}
+	return counts, nil
+}
+
+// 用户对一批对象做过的表态
+func (r *reactionRepo) ByUser(userID uint, targetType string, ids []uint) (map[uint][]string, error) {
+	kinds := map[uint][]string{}
+	if len(ids) == 0 || userID == 0 {
+		return kinds, nil
+	}
+	var reactions []Reaction
+	err := r.db.Where("user_id = ? AND target_type = ? AND target_id IN ?", userID, targetType, ids).
+		Order("id").
+		Find(&reactions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, reaction := range reactions {
+		kinds[reaction.TargetID] = append(kinds[reaction.TargetID], reaction.Kind)
+	}
+	return kinds, nil
+}
@@ -0,0 +1,532 @@
+package data
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 用户仓库
+type UserRepo interface {
+	Create(user *User) error
+	FindByUsername(username string) (*User, error)
+	FindByID(id uint) (*User, error)
+	FindByIDs(ids []uint) ([]User, error)
+	FindByEmail(email string) (*User, error)
+	UpdateRole(user *User, role *Role) error
+	MarkEmailVerified(user *User) error
+	UpdatePassword(user *User, hashedPassword string) error
+	FindLocale(id uint) (string, error)
+	UpdateLocale(user *User, locale string) error
+}
+
+// 文章仓库
+type PostRepo interface {
+	Create(post *Post) error
+	List(q ListQuery) (*Page[Post], error)
+	FindByID(id uint) (*Post, error)
+	FindByIDs(ids []uint) ([]Post, error)
+	Update(post *Post, changes *Post) error
+	SetTags(post *Post, tags []Tag) error
+	SetCategories(post *Post, categories []Category) error
+	SetMedia(post *Post, media []Media) error
+	PublishDue(now time.Time) ([]Post, error)
+	ListPublished(authorID, tagID uint, limit int) ([]Post, error)
+	SaveHTML(post *Post) error
+	AddViews(views map[uint]int64) error
+	Delete(post *Post) error
+}
+
+// 评论仓库
+type CommentRepo interface {
+	Create(comment *Comment) error
+	List(q ListQuery) (*Page[Comment], error)
+	FindByID(id uint) (*Comment, error)
+	ListReplies(parentIDs []uint, limit int) ([]Comment, error)
+	CountReplies(parentIDs []uint) (map[uint]int, error)
+	ListByPost(postID uint) ([]Comment, error)
+	Update(comment *Comment, content string) error
+	Delete(comment *Comment) error
+}
+
+// 各业务模块依赖的仓库集合，测试时可替换为假实现
+type Store struct {
+	Users      UserRepo
+	Posts      PostRepo
+	Comments   CommentRepo
+	Roles      RoleRepo
+	Search     SearchRepo
+	Tokens     TokenRepo
+	Logins     LoginRepo
+	Tags       TagRepo
+	Categories CategoryRepo
+	Revisions  RevisionRepo
+	Media      MediaRepo
+	Reactions  ReactionRepo
+	Changes    ChangeRepo
+
+	db *gorm.DB
+}
+
+// 基于共享连接池创建仓库集合
+func NewStore(db *gorm.DB) *Store {
+	return &Store{
+		db:         db,
+		Users:      &userRepo{db: db},
+		Posts:      &postRepo{db: db},
+		Comments:   &commentRepo{db: db},
+		Roles:      &roleRepo{db: db},
+		Search:     &searchRepo{db: db},
+		Tokens:     &tokenRepo{db: db},
+		Logins:     &loginRepo{db: db},
+		Tags:       &tagRepo{db: db},
+		Categories: &categoryRepo{db: db},
+		Revisions:  &revisionRepo{db: db},
+		Media:      &mediaRepo{db: db},
+		Reactions:  &reactionRepo{db: db},
+		Changes:    &changeRepo{db: db},
+	}
+}
+
+// 在一个事务中执行fn，fn中通过tx访问的仓库共享同一事务
+func (s *Store) Transaction(fn func(tx *Store) error) error {
+	//测试中的假实现没有数据库连接，直接在当前仓库上执行
+	if s.db == nil {
+		return fn(s)
+	}
+	return s.db.Transaction(func(db *gorm.DB) error {
+		return fn(NewStore(db))
+	})
+}
+
+type userRepo struct {
+	db *gorm.DB
+}
+
+func (r *userRepo) Create(user *User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *userRepo) FindByUsername(username string) (*User, error) {
+	var user User
+	if err := r.db.Preload("Role").Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) FindByID(id uint) (*User, error) {
+	var user User
+	if err := r.db.Preload("Role").First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) FindByIDs(ids []uint) ([]User, error) {
+	var users []User
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepo) FindByEmail(email string) (*User, error) {
+	var user User
+	if err := r.db.Preload("Role").Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepo) UpdateRole(user *User, role *Role) error {
+	//不使用Model(user)，避免gorm按已加载的Role关联回写role_id
+	if err := r.db.Model(&User{}).Where("id = ?", user.ID).Update("role_id", role.ID).Error; err != nil {
+		return err
+	}
+	user.RoleID = role.ID
+	user.Role = role
+	return nil
+}
+
+// 记录邮箱验证时间，已验证过的邮箱保留第一次验证的时间
+func (r *userRepo) MarkEmailVerified(user *User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := r.db.Model(&User{}).Where("id = ?", user.ID).Update("email_verified_at", now).Error; err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+func (r *userRepo) UpdatePassword(user *User, hashedPassword string) error {
+	if err := r.db.Model(&User{}).Where("id = ?", user.ID).Update("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	return nil
+}
+
+// 用户的语言偏好，只读取locale列
+func (r *userRepo) FindLocale(id uint) (string, error) {
+	var locale string
+	if err := r.db.Model(&User{}).Select("locale").Where("id = ?", id).Scan(&locale).Error; err != nil {
+		return "", err
+	}
+	return locale, nil
+}
+
+func (r *userRepo) UpdateLocale(user *User, locale string) error {
+	if err := r.db.Model(&User{}).Where("id = ?", user.ID).Update("locale", locale).Error; err != nil {
+		return err
+	}
+	user.Locale = locale
+	return nil
+}
+
+type postRepo struct {
+	db *gorm.DB
+}
+
+func (r *postRepo) Create(post *Post) error {
+	return r.db.Create(post).Error
+}
+
+// 文章可按创建时间、更新时间和标题排序
+var postSorts = map[string]sortField{
+	"created_at": timeSortField("created_at"),
+	"updated_at": timeSortField("updated_at"),
+	"title":      stringSortField("title"),
+}
+
+func (r *postRepo) List(q ListQuery) (*Page[Post], error) {
+	db := applyFilters(r.db.Model(&Post{}), q)
+
+	//已发布和归档的文章所有人可见，其他状态的文章只有作者可见
+	switch q.Status {
+	case "":
+		db = db.Where("status = ?", PostPublished)
+	case "all":
+	default:
+		db = db.Where("status = ?", q.Status)
+	}
+	db = db.Where("(status IN ? OR user_id = ?)", []string{PostPublished, PostArchived}, q.ViewerID)
+
+	if q.TagID != 0 {
+		db = db.Where("id IN (?)", r.db.Table("post_tags").Select("post_id").Where("tag_id = ?", q.TagID))
+	}
+	if q.CategoryID != 0 {
+		categoryIDs, err := (&categoryRepo{db: r.db}).Descendants(q.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("id IN (?)", r.db.Table("post_categories").Select("post_id").Where("category_id IN ?", categoryIDs))
+	}
+
+	page, err := paginate(db, q, postSorts, func(post *Post, sort string) (string, uint) {
+		switch sort {
+		case "updated_at":
+			return post.UpdatedAt.Format(time.RFC3339Nano), post.ID
+		case "title":
+			return post.Title, post.ID
+		}
+		return post.CreatedAt.Format(time.RFC3339Nano), post.ID
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadTaxonomy(page.Items); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// 为一批文章加载标签和分类（分页计数不能和Preload一起使用，单独查询）
+func (r *postRepo) loadTaxonomy(posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	var loaded []Post
+	if err := r.db.Preload("Tags").Preload("Categories").Select("id").Where("id IN ?", ids).Find(&loaded).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]*Post, len(loaded))
+	for i := range loaded {
+		byID[loaded[i].ID] = &loaded[i]
+	}
+	for i := range posts {
+		if post, ok := byID[posts[i].ID]; ok {
+			posts[i].Tags = post.Tags
+			posts[i].Categories = post.Categories
+		}
+	}
+	return nil
+}
+
+func (r *postRepo) FindByID(id uint) (*Post, error) {
+	var post Post
+	if err := r.db.Preload("Tags").Preload("Categories").Preload("Media").First(&post, id).Error; err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (r *postRepo) FindByIDs(ids []uint) ([]Post, error) {
+	var posts []Post
+	if err := r.db.Where("id IN ?", ids).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (r *postRepo) Update(post *Post, changes *Post) error {
+	return r.db.Model(post).Omit("Tags", "Categories", "Media").Updates(changes).Error
+}
+
+// 替换文章的全部标签
+func (r *postRepo) SetTags(post *Post, tags []Tag) error {
+	if err := r.db.Model(post).Association("Tags").Replace(tags); err != nil {
+		return err
+	}
+	post.Tags = tags
+	return nil
+}
+
+// 替换文章的全部分类
+func (r *postRepo) SetCategories(post *Post, categories []Category) error {
+	if err := r.db.Model(post).Association("Categories").Replace(categories); err != nil {
+		return err
+	}
+	post.Categories = categories
+	return nil
+}
+
+// 替换文章引用的全部文件
+func (r *postRepo) SetMedia(post *Post, media []Media) error {
+	if err := r.db.Model(post).Association("Media").Replace(media); err != nil {
+		return err
+	}
+	post.Media = media
+	return nil
+}
+
+// 发布到期的定时文章，返回本次发布的文章；多个进程同时执行时每篇文章只会被发布一次
+func (r *postRepo) PublishDue(now time.Time) ([]Post, error) {
+	var due []Post
+	if err := r.db.Where("status = ? AND publish_at <= ?", PostScheduled, now).Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	published := make([]Post, 0, len(due))
+	for _, post := range due {
+		result := r.db.Model(&Post{}).
+			Where("id = ? AND status = ?", post.ID, PostScheduled).
+			Update("status", PostPublished)
+		if result.Error != nil {
+			return published, result.Error
+		}
+		if result.RowsAffected == 1 {
+			post.Status = PostPublished
+			published = append(published, post)
+		}
+	}
+	return published, nil
+}
+
+// 按发布时间从新到旧读取已发布的文章，authorID和tagID不为0时按作者和标签过滤，limit为0时不限数量
+func (r *postRepo) ListPublished(authorID, tagID uint, limit int) ([]Post, error) {
+	db := r.db.Where("status = ?", PostPublished)
+	if authorID != 0 {
+		db = db.Where("user_id = ?", authorID)
+	}
+	if tagID != 0 {
+		db = db.Where("id IN (?)", r.db.Table("post_tags").Select("post_id").Where("tag_id = ?", tagID))
+	}
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+
+	var posts []Post
+	if err := db.Order("publish_at DESC").Order("id DESC").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	if err := r.loadTaxonomy(posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// 保存重新渲染的HTML缓存，不改变文章的更新时间
+func (r *postRepo) SaveHTML(post *Post) error {
+	return r.db.Model(post).UpdateColumns(map[string]interface{}{
+		"html":         post.HTML,
+		"html_version": post.HTMLVersion,
+	}).Error
+}
+
+// 累加一批文章的浏览数，按文章ID顺序更新，避免并发刷新时互相等待行锁
+func (r *postRepo) AddViews(views map[uint]int64) error {
+	ids := make([]uint, 0, len(views))
+	for id := range views {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			err := tx.Model(&Post{}).Where("id = ?", id).
+				UpdateColumn("view_count", gorm.Expr("view_count + ?", views[id])).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 删除文章及其修订、表态和与标签、分类、文件的关联
+func (r *postRepo) Delete(post *Post) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", post.ID).Delete(&PostRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", ReactionPost, post.ID).Delete(&Reaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Select("Tags", "Categories", "Media").Unscoped().Delete(post).Error
+	})
+}
+
+type commentRepo struct {
+	db *gorm.DB
+}
+
+func (r *commentRepo) Create(comment *Comment) error {
+	return r.db.Create(comment).Error
+}
+
+// 评论可按创建时间和更新时间排序
+var commentSorts = map[string]sortField{
+	"created_at": timeSortField("created_at"),
+	"updated_at": timeSortField("updated_at"),
+}
+
+func (r *commentRepo) List(q ListQuery) (*Page[Comment], error) {
+	db := r.db
+	if q.Deleted {
+		db = db.Unscoped()
+	}
+	db = applyCommentFilters(db.Model(&Comment{}), q)
+	return paginate(db, q, commentSorts, func(comment *Comment, sort string) (string, uint) {
+		if sort == "updated_at" {
+			return comment.UpdatedAt.Format(time.RFC3339Nano), comment.ID
+		}
+		return comment.CreatedAt.Format(time.RFC3339Nano), comment.ID
+	})
+}
+
+func (r *commentRepo) FindByID(id uint) (*Comment, error) {
+	var comment Comment
+	if err := r.db.First(&comment, id).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// 一次UNION ALL查询中最多合并的子查询数，sqlite的复合查询最多500项
+const maxUnionTerms = 500
+
+// 一批评论中每条评论的前limit条直接回复（包括已删除的回复，以便保留讨论结构），按发布时间排序
+func (r *commentRepo) ListReplies(parentIDs []uint, limit int) ([]Comment, error) {
+	var replies []Comment
+	//每条评论各用一个带LIMIT的子查询，每maxUnionTerms条评论合并为一次查询；MySQL 5.7不支持窗口函数
+	for chunk := range slices.Chunk(parentIDs, maxUnionTerms) {
+		parts := make([]string, len(chunk))
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			parts[i] = fmt.Sprintf("SELECT * FROM (?) AS r%d", i)
+			args[i] = r.db.Unscoped().Model(&Comment{}).Where("parent_id = ?", id).Order("created_at ASC, id ASC").Limit(limit)
+		}
+		var batch []Comment
+		if err := r.db.Raw(strings.Join(parts, " UNION ALL "), args...).Scan(&batch).Error; err != nil {
+			return nil, err
+		}
+		replies = append(replies, batch...)
+	}
+	slices.SortFunc(replies, func(a, b Comment) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return replies, nil
+}
+
+// 每条评论的直接回复数（包括已删除的）
+func (r *commentRepo) CountReplies(parentIDs []uint) (map[uint]int, error) {
+	counts := make(map[uint]int, len(parentIDs))
+	if len(parentIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ParentID uint
+		Count    int
+	}
+	err := r.db.Unscoped().Model(&Comment{}).
+		Select("parent_id, COUNT(*) AS count").
+		Where("parent_id IN ?", parentIDs).
+		Group("parent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ParentID] = row.Count
+	}
+	return counts, nil
+}
+
+// 文章下的全部评论（包括已删除的），按时间正序排列
+func (r *commentRepo) ListByPost(postID uint) ([]Comment, error) {
+	var comments []Comment
+	err := r.db.Unscoped().Where("post_id = ?", postID).Order("created_at ASC, id ASC").Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// 修改评论内容并记录编辑时间
+func (r *commentRepo) Update(comment *Comment, content string) error {
+	now := time.Now()
+	err := r.db.Model(comment).Updates(map[string]interface{}{
+		"content":   content,
+		"edited_at": now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	comment.Content = content
+	comment.EditedAt = &now
+	return nil
+}
+
+// 软删除评论，回复仍然保留在评论树中；评论的表态一并删除
+func (r *commentRepo) Delete(comment *Comment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("target_type = ? AND target_id = ?", ReactionComment, comment.ID).Delete(&Reaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(comment).Error
+	})
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 在临时目录中的sqlite数据库上创建仓库
+func openStore(t *testing.T, models ...interface{}) *Store {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(db)
+}
+
+// 父评论数超过sqlite复合查询的项数上限时分批查询
+func TestListRepliesManyParents(t *testing.T) {
+	s := openStore(t, &Comment{})
+	const parents = 3*maxUnionTerms/2 + 1
+
+	roots := make([]Comment, parents)
+	for i := range roots {
+		roots[i] = Comment{Content: "root", UserID: 1, PostID: 1}
+	}
+	if err := s.db.CreateInBatches(roots, 200).Error; err != nil {
+		t.Fatal(err)
+	}
+	var replies []Comment
+	ids := make([]uint, parents)
+	for i := range roots {
+		ids[i] = roots[i].ID
+		for range 2 {
+			replies = append(replies, Comment{Content: "reply", UserID: 1, PostID: 1, ParentID: &roots[i].ID, Depth: 1})
+		}
+	}
+	if err := s.db.CreateInBatches(replies, 200).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Comments.ListReplies(ids, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != parents {
+		t.Fatalf("got %d replies, want %d", len(got), parents)
+	}
+	seen := map[uint]bool{}
+	for i, reply := range got {
+		if seen[*reply.ParentID] {
+			t.Fatalf("parent %d has more than one reply", *reply.ParentID)
+		}
+		seen[*reply.ParentID] = true
+		if i > 0 && got[i-1].ID > reply.ID {
+			t.Fatalf("replies are not sorted: %d before %d", got[i-1].ID, reply.ID)
+		}
+	}
+
+	counts, err := s.Comments.CountReplies(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != parents || counts[ids[0]] != 2 {
+		t.Fatalf("counts = %d entries, first %d; want %d entries of 2", len(counts), counts[ids[0]], parents)
+	}
+}
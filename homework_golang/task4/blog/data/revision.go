@@ -0,0 +1,77 @@
+package data
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 文章修订：每次修改标题或内容后保存文章的完整快照，Number在同一篇文章内从1递增
+type PostRevision struct {
+	ID           uint      `gorm:"primaryKey" json:"-"`
+	PostID       uint      `gorm:"not null;uniqueIndex:idx_post_revisions_number" json:"post_id"`
+	Number       int       `gorm:"not null;uniqueIndex:idx_post_revisions_number" json:"number"`
+	Title        string    `gorm:"not null" json:"title"`
+	Content      string    `gorm:"not null" json:"content,omitempty"`
+	UserID       uint      `gorm:"not null" json:"user_id"` //修改者
+	RestoredFrom *int      `json:"restored_from,omitempty"` //由哪个修订恢复而来
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// 修订仓库
+type RevisionRepo interface {
+	Create(revision *PostRevision) error
+	List(postID uint) ([]PostRevision, error)
+	FindByNumber(postID uint, number int) (*PostRevision, error)
+}
+
+type revisionRepo struct {
+	db *gorm.DB
+}
+
+// 保存修订，编号为该文章已有的最大编号加1；需要在事务中调用，
+// 先锁住文章行，同一文章的并发修改排队取号，不会算出相同的编号
+func (r *revisionRepo) Create(revision *PostRevision) error {
+	var postID uint
+	err := r.db.Model(&Post{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", revision.PostID).
+		Select("id").
+		Scan(&postID).Error
+	if err != nil {
+		return err
+	}
+
+	var last int
+	err = r.db.Model(&PostRevision{}).
+		Where("post_id = ?", revision.PostID).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	revision.Number = last + 1
+	return r.db.Create(revision).Error
+}
+
+// 文章的全部修订，从新到旧排列，不包含内容
+func (r *revisionRepo) List(postID uint) ([]PostRevision, error) {
+	var revisions []PostRevision
+	err := r.db.Omit("content").
+		Where("post_id = ?", postID).
+		Order("number DESC").
+		Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *revisionRepo) FindByNumber(postID uint, number int) (*PostRevision, error) {
+	var revision PostRevision
+	if err := r.db.Where("post_id = ? AND number = ?", postID, number).First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"gorm.io/gorm"
+)
+
+// 内置角色
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleReader = "reader"
+)
+
+// 新注册用户的角色
+const DefaultRole = RoleAuthor
+
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"size:32;unique;not null" json:"name"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+}
+
+// 权限名格式为"资源:操作"或"资源:操作:范围"，如post:delete:any
+type Permission struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"size:64;unique;not null" json:"name"`
+}
+
+// 角色仓库
+type RoleRepo interface {
+	FindByName(name string) (*Role, error)
+	List() ([]Role, error)
+}
+
+type roleRepo struct {
+	db *gorm.DB
+}
+
+func (r *roleRepo) FindByName(name string) (*Role, error) {
+	var role Role
+	if err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepo) List() ([]Role, error) {
+	var roles []Role
+	if err := r.db.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
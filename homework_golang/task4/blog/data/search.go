@@ -0,0 +1,128 @@
+package data
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 倒排索引中的一行：词项在某篇文章中的加权词频
+type PostTerm struct {
+	ID     uint   `gorm:"primaryKey"`
+	PostID uint   `gorm:"not null;index"`
+	Term   string `gorm:"size:64;not null;index"`
+	Weight int    `gorm:"not null"`
+}
+
+// 搜索命中的文章及其相关度得分
+type TermMatch struct {
+	PostID uint
+	Score  float64
+}
+
+// 搜索索引仓库
+type SearchRepo interface {
+	ReplaceTerms(postID uint, weights map[string]int) error
+	DeleteTerms(postID uint) error
+	Match(idf map[string]float64, offset, limit int) ([]TermMatch, int64, error)
+	DocumentCount() (int64, error)
+	DocumentFrequency(terms []string) (map[string]int64, error)
+}
+
+type searchRepo struct {
+	db *gorm.DB
+}
+
+func (r *searchRepo) ReplaceTerms(postID uint, weights map[string]int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", postID).Delete(&PostTerm{}).Error; err != nil {
+			return err
+		}
+		if len(weights) == 0 {
+			return nil
+		}
+
+		rows := make([]PostTerm, 0, len(weights))
+		for term, weight := range weights {
+			rows = append(rows, PostTerm{PostID: postID, Term: term, Weight: weight})
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+}
+
+func (r *searchRepo) DeleteTerms(postID uint) error {
+	return r.db.Where("post_id = ?", postID).Delete(&PostTerm{}).Error
+}
+
+// 包含idf中全部词项的文章，在数据库中按得分从高到低排序并分页，同时返回命中总数；
+// 得分为各词项的对数词频(1+ln(weight))乘逆文档频率之和
+func (r *searchRepo) Match(idf map[string]float64, offset, limit int) ([]TermMatch, int64, error) {
+	terms := make([]string, 0, len(idf))
+	for term := range idf {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	matched := func() *gorm.DB {
+		return r.db.Model(&PostTerm{}).Where("term IN ?", terms).Group("post_id").Having("COUNT(*) = ?", len(terms))
+	}
+
+	var total int64
+	if err := r.db.Table("(?) AS matched", matched().Select("post_id")).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || int64(offset) >= total {
+		return nil, total, nil
+	}
+
+	//逆文档频率是程序算出的数值，直接写入SQL；词项作为参数传入
+	var score strings.Builder
+	args := make([]interface{}, 0, len(terms))
+	score.WriteString("post_id, SUM((1 + LN(weight)) * CASE term")
+	for _, term := range terms {
+		score.WriteString(" WHEN ? THEN " + strconv.FormatFloat(idf[term], 'f', -1, 64))
+		args = append(args, term)
+	}
+	score.WriteString(" END) AS score")
+
+	var matches []TermMatch
+	err := matched().Select(score.String(), args...).
+		Order("score DESC, post_id DESC").
+		Offset(offset).
+		Limit(limit).
+		Scan(&matches).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return matches, total, nil
+}
+
+// 已建立索引的文章数
+func (r *searchRepo) DocumentCount() (int64, error) {
+	var count int64
+	err := r.db.Model(&PostTerm{}).Distinct("post_id").Count(&count).Error
+	return count, err
+}
+
+// 每个词项出现在多少篇文章中
+func (r *searchRepo) DocumentFrequency(terms []string) (map[string]int64, error) {
+	var rows []struct {
+		Term  string
+		Count int64
+	}
+	err := r.db.Model(&PostTerm{}).
+		Select("term, COUNT(*) AS count").
+		Where("term IN ?", terms).
+		Group("term").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	df := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		df[row.Term] = row.Count
+	}
+	return df, nil
+}
@@ -0,0 +1,231 @@
+package data
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 标签，作者给文章打标签时自动创建；Slug为规范化后的名称，用于去重和链接
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:64;not null" json:"name"`
+	Slug      string    `gorm:"size:64;unique;not null" json:"slug"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// 标签及其文章数，用于标签云
+type TagCount struct {
+	Tag
+	Count int64 `json:"count"`
+}
+
+// 分类，ParentID为空的是顶级分类
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:64;not null" json:"name"`
+	Slug      string    `gorm:"size:64;unique;not null" json:"slug"`
+	ParentID  *uint     `gorm:"index" json:"parent_id"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// 标签仓库
+type TagRepo interface {
+	Create(tag *Tag) error
+	FindByID(id uint) (*Tag, error)
+	FindBySlug(slug string) (*Tag, error)
+	FindOrCreate(tags []Tag) ([]Tag, error)
+	Counts(limit int) ([]TagCount, error)
+	Update(tag *Tag, name, slug string) error
+	Delete(tag *Tag) error
+}
+
+// 分类仓库
+type CategoryRepo interface {
+	Create(category *Category) error
+	FindByID(id uint) (*Category, error)
+	FindBySlug(slug string) (*Category, error)
+	FindByIDs(ids []uint) ([]Category, error)
+	List() ([]Category, error)
+	PostCounts() (map[uint]int64, error)
+	Descendants(id uint) ([]uint, error)
+	Update(category *Category, changes map[string]interface{}) error
+	Delete(category *Category) error
+}
+
+type tagRepo struct {
+	db *gorm.DB
+}
+
+func (r *tagRepo) Create(tag *Tag) error {
+	return r.db.Create(tag).Error
+}
+
+func (r *tagRepo) FindByID(id uint) (*Tag, error) {
+	var tag Tag
+	if err := r.db.First(&tag, id).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *tagRepo) FindBySlug(slug string) (*Tag, error) {
+	var tag Tag
+	if err := r.db.Where("slug = ?", slug).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// 按Slug查找标签，不存在的标签随即创建；返回顺序与传入顺序一致
+func (r *tagRepo) FindOrCreate(tags []Tag) ([]Tag, error) {
+	result := make([]Tag, 0, len(tags))
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, tag := range tags {
+			if err := tx.Where("slug = ?", tag.Slug).Attrs(Tag{Name: tag.Name}).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			result = append(result, tag)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 标签云：按已发布文章数从多到少排列，limit为0时返回全部标签；草稿、定时和已删除的文章不计入
+func (r *tagRepo) Counts(limit int) ([]TagCount, error) {
+	var counts []TagCount
+	db := r.db.Model(&Tag{}).
+		Select("tags.*, COUNT(posts.id) AS count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.status = ? AND posts.deleted_at IS NULL", PostPublished).
+		Group("tags.id").
+		Order("count DESC, tags.name ASC")
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err := db.Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (r *tagRepo) Update(tag *Tag, name, slug string) error {
+	if err := r.db.Model(tag).Updates(Tag{Name: name, Slug: slug}).Error; err != nil {
+		return err
+	}
+	tag.Name = name
+	tag.Slug = slug
+	return nil
+}
+
+// 删除标签及其与文章的关联
+func (r *tagRepo) Delete(tag *Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tag).Error
+	})
+}
+
+type categoryRepo struct {
+	db *gorm.DB
+}
+
+func (r *categoryRepo) Create(category *Category) error {
+	return r.db.Create(category).Error
+}
+
+func (r *categoryRepo) FindByID(id uint) (*Category, error) {
+	var category Category
+	if err := r.db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *categoryRepo) FindBySlug(slug string) (*Category, error) {
+	var category Category
+	if err := r.db.Where("slug = ?", slug).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *categoryRepo) FindByIDs(ids []uint) ([]Category, error) {
+	var categories []Category
+	if err := r.db.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *categoryRepo) List() ([]Category, error) {
+	var categories []Category
+	if err := r.db.Order("name ASC, id ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// 每个分类直接关联的已发布文章数
+func (r *categoryRepo) PostCounts() (map[uint]int64, error) {
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err := r.db.Table("post_categories").
+		Select("post_categories.category_id, COUNT(*) AS count").
+		Joins("JOIN posts ON posts.id = post_categories.post_id AND posts.status = ? AND posts.deleted_at IS NULL", PostPublished).
+		Group("post_categories.category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	return counts, nil
+}
+
+// 分类自身及其所有子孙分类的ID，逐层查询
+func (r *categoryRepo) Descendants(id uint) ([]uint, error) {
+	ids := []uint{id}
+	level := []uint{id}
+	for len(level) > 0 {
+		var children []uint
+		if err := r.db.Model(&Category{}).Where("parent_id IN ?", level).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, children...)
+		level = children
+	}
+	return ids, nil
+}
+
+func (r *categoryRepo) Update(category *Category, changes map[string]interface{}) error {
+	if err := r.db.Model(category).Updates(changes).Error; err != nil {
+		return err
+	}
+	return r.db.First(category, category.ID).Error
+}
+
+// 删除分类：子分类移到被删除分类的上级，文章只解除与该分类的关联
+func (r *categoryRepo) Delete(category *Category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Category{}).Where("parent_id = ?", category.ID).Update("parent_id", category.ParentID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM post_categories WHERE category_id = ?", category.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
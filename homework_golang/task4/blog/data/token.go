@@ -0,0 +1,134 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 刷新令牌，只保存令牌的哈希；同一次登录轮换出的令牌属于同一令牌族
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	FamilyID  string     `gorm:"size:64;not null;index"`
+	TokenHash string     `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time //已轮换出新令牌的时间
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// 吊销列表，TokenID为访问令牌的jti或令牌族ID，过期后可清理
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenID   string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// 令牌仓库
+type TokenRepo interface {
+	CreateRefresh(token *RefreshToken) error
+	FindRefreshByHash(hash string) (*RefreshToken, error)
+	MarkRefreshUsed(token *RefreshToken) (bool, error)
+	RevokeFamily(familyID string, until time.Time) error
+	RevokeUser(userID uint, until time.Time) error
+	Revoke(tokenID string, until time.Time) error
+	IsRevoked(tokenIDs ...string) (bool, error)
+	PurgeExpired(now time.Time) error
+}
+
+type tokenRepo struct {
+	db *gorm.DB
+}
+
+func (r *tokenRepo) CreateRefresh(token *RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *tokenRepo) FindRefreshByHash(hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	if err := r.db.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// 标记令牌已轮换，令牌已被使用或已吊销时返回false（并发刷新时只有一个请求能成功）
+func (r *tokenRepo) MarkRefreshUsed(token *RefreshToken) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	token.UsedAt = &now
+	return true, nil
+}
+
+// 吊销整个令牌族：族内的刷新令牌全部失效，族内签发的访问令牌在until之前保持吊销
+func (r *tokenRepo) RevokeFamily(familyID string, until time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return (&tokenRepo{db: tx}).Revoke(familyID, until)
+	})
+}
+
+// 吊销用户所有未失效的令牌族，用于重置密码等需要让所有登录会话失效的场景
+func (r *tokenRepo) RevokeUser(userID uint, until time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var familyIDs []string
+		err := tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Distinct().Pluck("family_id", &familyIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, familyID := range familyIDs {
+			if err := (&tokenRepo{db: tx}).RevokeFamily(familyID, until); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *tokenRepo) Revoke(tokenID string, until time.Time) error {
+	var existing RevokedToken
+	err := r.db.Where("token_id = ?", tokenID).First(&existing).Error
+	if err == nil {
+		if until.After(existing.ExpiresAt) {
+			return r.db.Model(&existing).Update("expires_at", until).Error
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.Create(&RevokedToken{TokenID: tokenID, ExpiresAt: until}).Error
+}
+
+func (r *tokenRepo) IsRevoked(tokenIDs ...string) (bool, error) {
+	var count int64
+	err := r.db.Model(&RevokedToken{}).
+		Where("token_id IN ? AND expires_at > ?", tokenIDs, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 清理过期的刷新令牌和吊销记录
+func (r *tokenRepo) PurgeExpired(now time.Time) error {
+	if err := r.db.Where("expires_at <= ?", now).Delete(&RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("expires_at <= ?", now).Delete(&RevokedToken{}).Error
+}
@@ -0,0 +1,219 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 差异中一行的操作
+const (
+	Equal  = ' '
+	Insert = '+'
+	Delete = '-'
+)
+
+// 比较的文本最多的行数，比较耗时与行数和差异大小的乘积成正比
+const MaxLines = 5000
+
+// 差异中的一行
+type Line struct {
+	Op   byte   `json:"-"`
+	Text string `json:"text"`
+}
+
+// 按行比较a和b，返回把a变为b的最短编辑序列（Myers算法）
+func Lines(a, b []string) []Line {
+	return compare(a, b, make([]Line, 0, len(a)+len(b)))
+}
+
+// 比较a和b，把编辑序列追加到result后返回
+func compare(a, b []string, result []Line) []Line {
+	//先去掉相同的首尾，只比较中间不同的部分
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, text := range a[:prefix] {
+		result = append(result, Line{Op: Equal, Text: text})
+	}
+	result = bisect(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], result)
+	for _, text := range a[len(a)-suffix:] {
+		result = append(result, Line{Op: Equal, Text: text})
+	}
+	return result
+}
+
+// 从两端同时搜索最短编辑路径，在两个方向的路径相遇处（middle snake）把问题一分为二递归比较；
+// 每层只保存当前的v，内存与行数成正比，而不是与行数和编辑距离的乘积成正比
+func bisect(a, b []string, result []Line) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		for _, text := range a {
+			result = append(result, Line{Op: Delete, Text: text})
+		}
+		for _, text := range b {
+			result = append(result, Line{Op: Insert, Text: text})
+		}
+		return result
+	}
+
+	//v1[k+offset]为正向搜索在对角线k上走得最远的x，v2为反向搜索从末尾算起走得最远的x
+	maxD := (n + m + 1) / 2
+	offset, length := maxD, 2*maxD+2
+	v1, v2 := make([]int, length), make([]int, length)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+	delta := n - m
+	//总长度为奇数时，两个方向的路径在正向搜索中相遇，否则在反向搜索中相遇
+	front := delta%2 != 0
+	//超出编辑图边界的对角线不再搜索
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
+			i := offset + k1
+			var x1 int
+			if k1 == -d || (k1 != d && v1[i-1] < v1[i+1]) {
+				x1 = v1[i+1]
+			} else {
+				x1 = v1[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[i] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				if j := offset + delta - k1; j >= 0 && j < length && v2[j] != -1 && x1 >= n-v2[j] {
+					return split(a, b, x1, y1, result)
+				}
+			}
+		}
+
+		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
+			i := offset + k2
+			var x2 int
+			if k2 == -d || (k2 != d && v2[i-1] < v2[i+1]) {
+				x2 = v2[i+1]
+			} else {
+				x2 = v2[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[i] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				if j := offset + delta - k2; j >= 0 && j < length && v1[j] != -1 {
+					x1 := v1[j]
+					y1 := offset + x1 - j
+					if x1 >= n-x2 {
+						return split(a, b, x1, y1, result)
+					}
+				}
+			}
+		}
+	}
+
+	//没有共同的行
+	for _, text := range a {
+		result = append(result, Line{Op: Delete, Text: text})
+	}
+	for _, text := range b {
+		result = append(result, Line{Op: Insert, Text: text})
+	}
+	return result
+}
+
+// 在(x, y)处把比较分为前后两部分
+func split(a, b []string, x, y int, result []Line) []Line {
+	result = compare(a[:x], b[:y], result)
+	return compare(a[x:], b[y:], result)
+}
+
+// 把文本按行切分，统一换行符
+func Split(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// 生成统一格式（unified diff）的差异，context为每处修改前后保留的相同行数
+func Unified(fromName, toName string, lines []Line, context int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+
+	//找出所有修改行，前后各扩展context行，相邻的合并为一个hunk
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].Op == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		hunkStart := max(first-context, start)
+		end := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].Op != Equal {
+				end = i + 1
+			} else if i-end >= 2*context {
+				break
+			}
+		}
+		hunkEnd := min(end+context, len(lines))
+
+		//hunk头中的行号从1开始，计算该hunk之前两边各有多少行
+		fromLine, toLine := 1, 1
+		for _, line := range lines[:hunkStart] {
+			if line.Op != Insert {
+				fromLine++
+			}
+			if line.Op != Delete {
+				toLine++
+			}
+		}
+		fromCount, toCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.Op != Insert {
+				fromCount++
+			}
+			if line.Op != Delete {
+				toCount++
+			}
+		}
+		if fromCount == 0 {
+			fromLine--
+		}
+		if toCount == 0 {
+			toLine--
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+		for _, line := range lines[hunkStart:hunkEnd] {
+			b.WriteByte(line.Op)
+			b.WriteString(line.Text)
+			b.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+	return b.String()
+}
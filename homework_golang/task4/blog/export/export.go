@@ -0,0 +1,417 @@
+package export
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"blog/feed"
+	"blog/render"
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 未配置时首页每页的文章数
+const defaultPageSize = 10
+
+//go:embed templates/*.html
+var builtinTemplates embed.FS
+
+var store *data.Store
+
+// 注入静态导出依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 导出选项，未设置的字段取配置文件中的值
+type Options struct {
+	OutputDir   string
+	TemplateDir string
+	PageSize    int
+}
+
+// 导出结果
+type Result struct {
+	Posts int
+	Pages int
+	Tags  int
+	Files int
+}
+
+// 模板中的站点信息
+type Site struct {
+	Title       string
+	Description string
+	BaseURL     string
+}
+
+// 模板中的文章
+type Post struct {
+	ID           uint
+	Title        string
+	URL          string
+	Author       string
+	HTML         template.HTML
+	PublishedAt  time.Time
+	UpdatedAt    time.Time
+	Tags         []Tag
+	CommentCount int
+}
+
+// 模板中的标签
+type Tag struct {
+	Name string
+	Slug string
+	URL  string
+}
+
+// 模板中的评论，已删除的评论只保留位置以显示其回复
+type Comment struct {
+	ID        uint
+	Author    string
+	Content   string
+	CreatedAt time.Time
+	Deleted   bool
+	Replies   []*Comment
+}
+
+// 首页（index.html模板）
+type IndexPage struct {
+	Site       Site
+	Title      string
+	Posts      []Post
+	Page       int
+	TotalPages int
+	PrevURL    string
+	NextURL    string
+}
+
+// 文章页（post.html模板）
+type PostPage struct {
+	Site     Site
+	Title    string
+	Post     Post
+	Comments []*Comment
+}
+
+// 标签页（tag.html模板）
+type TagPage struct {
+	Site  Site
+	Title string
+	Tag   Tag
+	Posts []Post
+}
+
+type exporter struct {
+	opts      Options
+	site      Site
+	templates *template.Template
+	names     map[uint]string //用户ID到用户名
+	result    Result
+}
+
+// 把已发布的文章、评论、首页、标签页、订阅源和站点地图导出为静态文件
+func Export(opts Options) (*Result, error) {
+	if opts.OutputDir == "" {
+		opts.OutputDir = cfg.CFG.Export.OutputDir
+	}
+	if opts.OutputDir == "" {
+		return nil, fmt.Errorf("output directory is required")
+	}
+	if opts.TemplateDir == "" {
+		opts.TemplateDir = cfg.CFG.Export.TemplateDir
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = cfg.CFG.Export.PageSize
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+
+	templates, err := loadTemplates(opts.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	e := &exporter{
+		opts: opts,
+		site: Site{
+			Title:       cfg.CFG.Site.Title,
+			Description: cfg.CFG.Site.Description,
+			BaseURL:     strings.TrimRight(cfg.CFG.Site.BaseURL, "/"),
+		},
+		templates: templates,
+		names:     map[uint]string{},
+	}
+	if err := e.run(); err != nil {
+		return nil, err
+	}
+	return &e.result, nil
+}
+
+// 先加载内置模板，再用自定义模板目录中的同名模板覆盖
+func loadTemplates(dir string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"date": func(t time.Time) string { return t.Format("2006-01-02") },
+	}
+	templates, err := template.New("").Funcs(funcs).ParseFS(builtinTemplates, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return templates, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no templates found in %s", dir)
+	}
+	return templates.ParseFiles(files...)
+}
+
+// 导出时生成的目录，每次导出前清空，避免残留已删除或撤回的文章
+var generatedDirs = []string{"posts", "page", "tags", "authors"}
+
+func (e *exporter) run() error {
+	for _, dir := range generatedDirs {
+		if err := os.RemoveAll(filepath.Join(e.opts.OutputDir, dir)); err != nil {
+			return err
+		}
+	}
+
+	posts, err := store.Posts.ListPublished(0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	//文章页
+	views := make([]Post, len(posts))
+	for i := range posts {
+		comments, err := store.Comments.ListByPost(posts[i].ID)
+		if err != nil {
+			return err
+		}
+		if err := e.loadNames(posts[i].UserID, comments); err != nil {
+			return err
+		}
+		views[i] = e.post(&posts[i], comments)
+		page := PostPage{Site: e.site, Title: views[i].Title, Post: views[i], Comments: e.commentTree(comments)}
+		if err := e.render("post.html", postPath(views[i].ID), page); err != nil {
+			return err
+		}
+	}
+	e.result.Posts = len(views)
+
+	//首页，第1页为index.html，之后为page/N/index.html
+	totalPages := max((len(views)+e.opts.PageSize-1)/e.opts.PageSize, 1)
+	for page := 1; page <= totalPages; page++ {
+		start := (page - 1) * e.opts.PageSize
+		end := min(start+e.opts.PageSize, len(views))
+		index := IndexPage{Site: e.site, Posts: views[start:end], Page: page, TotalPages: totalPages}
+		if page > 1 {
+			index.PrevURL = e.site.BaseURL + "/" + strings.TrimSuffix(indexPath(page-1), "index.html")
+		}
+		if page < totalPages {
+			index.NextURL = e.site.BaseURL + "/" + strings.TrimSuffix(indexPath(page+1), "index.html")
+		}
+		if err := e.render("index.html", indexPath(page), index); err != nil {
+			return err
+		}
+	}
+	e.result.Pages = totalPages
+
+	//标签页和标签订阅源，按文章中出现的顺序导出
+	var tags []data.Tag
+	tagPosts := map[uint][]Post{}
+	for i := range posts {
+		for _, tag := range posts[i].Tags {
+			if _, ok := tagPosts[tag.ID]; !ok {
+				tags = append(tags, tag)
+			}
+			tagPosts[tag.ID] = append(tagPosts[tag.ID], views[i])
+		}
+	}
+	for i := range tags {
+		tag := e.tag(&tags[i])
+		page := TagPage{Site: e.site, Title: "#" + tag.Name, Tag: tag, Posts: tagPosts[tags[i].ID]}
+		if err := e.render("tag.html", "tags/"+tag.Slug+"/index.html", page); err != nil {
+			return err
+		}
+		if err := e.feeds("/tags/"+tag.Slug, nil, &tags[i]); err != nil {
+			return err
+		}
+	}
+	e.result.Tags = len(tags)
+
+	//作者订阅源
+	authors := map[uint]bool{}
+	for i := range posts {
+		if authors[posts[i].UserID] {
+			continue
+		}
+		authors[posts[i].UserID] = true
+		author := &data.User{Username: e.names[posts[i].UserID]}
+		author.ID = posts[i].UserID
+		//用户名没有限制字符，目录名使用用户ID
+		if err := e.feeds("/authors/"+strconv.FormatUint(uint64(author.ID), 10), author, nil); err != nil {
+			return err
+		}
+	}
+
+	//全站订阅源和站点地图
+	if err := e.feeds("", nil, nil); err != nil {
+		return err
+	}
+	sitemap, err := feed.SitemapXML()
+	if err != nil {
+		return err
+	}
+	if err := e.write("sitemap.xml", sitemap); err != nil {
+		return err
+	}
+
+	//GitHub Pages默认用Jekyll处理站点，导出的已经是最终文件
+	return e.write(".nojekyll", nil)
+}
+
+// 导出某个范围的RSS和Atom订阅源，prefix为订阅源所在的路径
+func (e *exporter) feeds(prefix string, author *data.User, tag *data.Tag) error {
+	rss, err := feed.RSSFeed(prefix+"/feed.rss", author, tag)
+	if err != nil {
+		return err
+	}
+	if err := e.write(strings.TrimPrefix(prefix+"/feed.rss", "/"), rss); err != nil {
+		return err
+	}
+	atom, err := feed.AtomFeed(prefix+"/feed.atom", author, tag)
+	if err != nil {
+		return err
+	}
+	return e.write(strings.TrimPrefix(prefix+"/feed.atom", "/"), atom)
+}
+
+// 查出文章作者和评论者中还不知道的用户名
+func (e *exporter) loadNames(authorID uint, comments []data.Comment) error {
+	var ids []uint
+	if _, ok := e.names[authorID]; !ok {
+		ids = append(ids, authorID)
+	}
+	for _, comment := range comments {
+		if _, ok := e.names[comment.UserID]; !ok {
+			ids = append(ids, comment.UserID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	users, err := store.Users.FindByIDs(ids)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		e.names[user.ID] = user.Username
+	}
+	return nil
+}
+
+func (e *exporter) post(post *data.Post, comments []data.Comment) Post {
+	html, err := render.Cached(post.Format, post.Content, post.HTML, post.HTMLVersion)
+	if err != nil {
+		zap.L().Warn("failed to render post", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+	view := Post{
+		ID:          post.ID,
+		Title:       post.Title,
+		URL:         e.site.BaseURL + "/" + strings.TrimSuffix(postPath(post.ID), "index.html"),
+		Author:      e.names[post.UserID],
+		HTML:        template.HTML(html), //已经过XSS过滤
+		PublishedAt: post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		Tags:        make([]Tag, len(post.Tags)),
+	}
+	if post.PublishAt != nil {
+		view.PublishedAt = *post.PublishAt
+	}
+	for i := range post.Tags {
+		view.Tags[i] = e.tag(&post.Tags[i])
+	}
+	for _, comment := range comments {
+		if !comment.DeletedAt.Valid {
+			view.CommentCount++
+		}
+	}
+	return view
+}
+
+func (e *exporter) tag(tag *data.Tag) Tag {
+	return Tag{Name: tag.Name, Slug: tag.Slug, URL: e.site.BaseURL + "/tags/" + tag.Slug + "/"}
+}
+
+// 把按时间排列的评论组织成树，父评论不存在的回复作为顶层评论
+func (e *exporter) commentTree(comments []data.Comment) []*Comment {
+	byID := make(map[uint]*Comment, len(comments))
+	roots := []*Comment{}
+	for _, comment := range comments {
+		node := &Comment{ID: comment.ID, CreatedAt: comment.CreatedAt, Replies: []*Comment{}}
+		if comment.DeletedAt.Valid {
+			node.Deleted = true
+		} else {
+			node.Author = e.names[comment.UserID]
+			node.Content = comment.Content
+		}
+		byID[comment.ID] = node
+
+		if comment.ParentID != nil {
+			if parent, ok := byID[*comment.ParentID]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+func (e *exporter) render(name, path string, data interface{}) error {
+	var buf strings.Builder
+	if err := e.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return e.write(path, []byte(buf.String()))
+}
+
+func (e *exporter) write(path string, content []byte) error {
+	root := filepath.Clean(e.opts.OutputDir)
+	full := filepath.Join(root, filepath.FromSlash(path))
+	//路径中含有..等时可能写到导出目录之外
+	if rel, err := filepath.Rel(root, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("export path %q is outside the output directory", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(full, content, 0o644); err != nil {
+		return err
+	}
+	e.result.Files++
+	return nil
+}
+
+func postPath(id uint) string {
+	return "posts/" + strconv.FormatUint(uint64(id), 10) + "/index.html"
+}
+
+func indexPath(page int) string {
+	if page == 1 {
+		return "index.html"
+	}
+	return "page/" + strconv.Itoa(page) + "/index.html"
+}
@@ -0,0 +1,97 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom订阅源：/feed.atom、/authors/:username/feed.atom、/tags/:slug/feed.atom
+func Atom(c *gin.Context) {
+	src, ok := loadSource(c)
+	if !ok {
+		return
+	}
+	serveXML(c, "application/atom+xml; charset=utf-8", src.updated, src.atom())
+}
+
+func (src *source) atom() atomFeed {
+	//没有文章时updated取固定值，保证内容不变时ETag不变
+	updated := src.updated.UTC()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	feed := atomFeed{
+		ID:       src.self,
+		Title:    src.title,
+		Subtitle: src.description,
+		Updated:  updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: src.self, Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL() + "/", Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]atomEntry, 0, len(src.posts)),
+	}
+	for i := range src.posts {
+		post := &src.posts[i]
+		entry := atomEntry{
+			ID:        postURL(post),
+			Title:     post.Title,
+			Link:      atomLink{Href: postURL(post), Rel: "alternate", Type: "text/html"},
+			Published: published(post).Format(time.RFC3339),
+			Updated:   post.UpdatedAt.UTC().Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Value: postHTML(post)},
+		}
+		if name, ok := src.authors[post.UserID]; ok {
+			entry.Author = &atomAuthor{Name: name}
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag.Slug, Label: tag.Name})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
@@ -0,0 +1,210 @@
+package feed
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"blog/logMnt"
+	"blog/render"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 未配置时订阅源中的文章数
+const defaultLimit = 20
+
+var store *data.Store
+
+// 注入订阅源模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 一个订阅源的内容，由RSS和Atom共用
+type source struct {
+	title       string
+	description string
+	self        string //订阅源自身的地址
+	posts       []data.Post
+	authors     map[uint]string //作者ID到用户名
+	updated     time.Time       //最近一篇文章的更新时间，与记录的变化时间一起决定Last-Modified
+}
+
+// 站点地址，不带末尾的斜杠
+func baseURL() string {
+	return strings.TrimRight(cfg.CFG.Site.BaseURL, "/")
+}
+
+// 文章的公开地址
+func postURL(post *data.Post) string {
+	return baseURL() + "/posts/" + strconv.FormatUint(uint64(post.ID), 10)
+}
+
+// 文章的发布时间，缺失时使用创建时间
+func published(post *data.Post) time.Time {
+	if post.PublishAt != nil {
+		return post.PublishAt.UTC()
+	}
+	return post.CreatedAt.UTC()
+}
+
+// 文章的HTML，缓存过期时临时渲染
+func postHTML(post *data.Post) string {
+	html, err := render.Cached(post.Format, post.Content, post.HTML, post.HTMLVersion)
+	if err != nil {
+		zap.L().Warn("failed to render post", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+	return html
+}
+
+// 读取全站、某个作者或某个标签（author和tag为nil表示不限）的订阅源，path为订阅源自身的路径
+func newSource(path string, author *data.User, tag *data.Tag) (*source, error) {
+	limit := cfg.CFG.Site.FeedLimit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	src := &source{
+		title:       cfg.CFG.Site.Title,
+		description: cfg.CFG.Site.Description,
+		self:        baseURL() + path,
+		authors:     map[uint]string{},
+	}
+
+	var authorID, tagID uint
+	if author != nil {
+		authorID = author.ID
+		src.title += " - " + author.Username
+	}
+	if tag != nil {
+		tagID = tag.ID
+		src.title += " - " + tag.Name
+	}
+
+	posts, err := store.Posts.ListPublished(authorID, tagID, limit)
+	if err != nil {
+		return nil, err
+	}
+	src.posts = posts
+
+	//查出作者用户名
+	ids := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.UserID)
+		if post.UpdatedAt.After(src.updated) {
+			src.updated = post.UpdatedAt
+		}
+	}
+	if len(ids) > 0 {
+		users, err := store.Users.FindByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			src.authors[user.ID] = user.Username
+		}
+	}
+	return src, nil
+}
+
+// 按路由参数读取全站、某个作者（:username）或某个标签（:slug）的订阅源，失败时写入错误响应并返回false
+func loadSource(c *gin.Context) (*source, bool) {
+	var author *data.User
+	var tag *data.Tag
+	var err error
+	if username := c.Param("username"); username != "" {
+		if author, err = store.Users.FindByUsername(username); err != nil {
+			c.Error(logMnt.ErrUserNotFound)
+			return nil, false
+		}
+	}
+	if slug := c.Param("slug"); slug != "" {
+		if tag, err = store.Tags.FindBySlug(slug); err != nil {
+			c.Error(logMnt.ErrTagNotFound)
+			return nil, false
+		}
+	}
+
+	src, err := newSource(c.Request.URL.Path, author, tag)
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get feed: %w", err)))
+		return nil, false
+	}
+	return src, true
+}
+
+// 生成RSS订阅源，供静态导出使用
+func RSSFeed(path string, author *data.User, tag *data.Tag) ([]byte, error) {
+	src, err := newSource(path, author, tag)
+	if err != nil {
+		return nil, err
+	}
+	return encode(src.rss())
+}
+
+// 生成Atom订阅源，供静态导出使用
+func AtomFeed(path string, author *data.User, tag *data.Tag) ([]byte, error) {
+	src, err := newSource(path, author, tag)
+	if err != nil {
+		return nil, err
+	}
+	return encode(src.atom())
+}
+
+// 生成站点地图，供静态导出使用
+func SitemapXML() ([]byte, error) {
+	set, _, err := sitemap()
+	if err != nil {
+		return nil, err
+	}
+	return encode(set)
+}
+
+// 编码为带XML声明的文档
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 记录订阅源的内容发生了变化：文章撤回、删除和标签改名不会推进文章的更新时间，由修改的一方调用；失败只记录日志
+func Touch() {
+	if err := store.Changes.Touch(data.ChangeFeed, time.Now()); err != nil {
+		zap.L().Warn("failed to record feed change", zap.Error(err))
+	}
+}
+
+// 以XML输出，带ETag和Last-Modified，客户端的条件请求命中时返回304；
+// Last-Modified取modified和Touch记录的最近变化时间中较晚的一个
+func serveXML(c *gin.Context, contentType string, modified time.Time, v interface{}) {
+	body, err := encode(v)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("get feed: %w", err)))
+		return
+	}
+	changed, err := store.Changes.LastChanged(data.ChangeFeed)
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get feed: %w", err)))
+		return
+	}
+	if changed.After(modified) {
+		modified = changed
+	}
+
+	sum := sha256.Sum256(body)
+	c.Header("Content-Type", contentType)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Cache-Control", "public, max-age=300")
+	http.ServeContent(c.Writer, c.Request, "", modified, bytes.NewReader(body))
+}
@@ -0,0 +1,90 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS 2.0订阅源：/feed.rss、/authors/:username/feed.rss、/tags/:slug/feed.rss
+func RSS(c *gin.Context) {
+	src, ok := loadSource(c)
+	if !ok {
+		return
+	}
+	serveXML(c, "application/rss+xml; charset=utf-8", src.updated, src.rss())
+}
+
+func (src *source) rss() rss {
+	feed := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       src.title,
+			Link:        baseURL() + "/",
+			Description: src.description,
+			Self:        rssLink{Href: src.self, Rel: "self", Type: "application/rss+xml"},
+			Items:       make([]rssItem, 0, len(src.posts)),
+		},
+	}
+	if !src.updated.IsZero() {
+		feed.Channel.LastBuildDate = src.updated.UTC().Format(time.RFC1123Z)
+	}
+	for i := range src.posts {
+		post := &src.posts[i]
+		item := rssItem{
+			Title:       post.Title,
+			Link:        postURL(post),
+			GUID:        rssGUID{IsPermaLink: true, Value: postURL(post)},
+			PubDate:     published(post).Format(time.RFC1123Z),
+			Creator:     src.authors[post.UserID],
+			Description: postHTML(post),
+		}
+		for _, tag := range post.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return feed
+}
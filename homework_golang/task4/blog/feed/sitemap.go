@@ -0,0 +1,62 @@
+package feed
+
+import (
+	"blog/logMnt"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单个站点地图最多包含的地址数
+const sitemapLimit = 50000
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// 站点地图：首页和全部已发布的文章
+func Sitemap(c *gin.Context) {
+	set, modified, err := sitemap()
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get sitemap: %w", err)))
+		return
+	}
+	serveXML(c, "application/xml; charset=utf-8", modified, set)
+}
+
+// 生成站点地图，同时返回最近一篇文章的更新时间
+func sitemap() (urlSet, time.Time, error) {
+	posts, err := store.Posts.ListPublished(0, 0, sitemapLimit-1)
+	if err != nil {
+		return urlSet{}, time.Time{}, err
+	}
+
+	var modified time.Time
+	for _, post := range posts {
+		if post.UpdatedAt.After(modified) {
+			modified = post.UpdatedAt
+		}
+	}
+
+	set := urlSet{URLs: make([]sitemapURL, 0, len(posts)+1)}
+	home := sitemapURL{Loc: baseURL() + "/"}
+	if !modified.IsZero() {
+		home.LastMod = modified.UTC().Format(time.RFC3339)
+	}
+	set.URLs = append(set.URLs, home)
+	for i := range posts {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     postURL(&posts[i]),
+			LastMod: posts[i].UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return set, modified, nil
+}
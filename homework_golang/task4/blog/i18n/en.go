@@ -0,0 +1,62 @@
+package i18n
+
+var en = map[string]string{
+	//通用错误
+	"database_error":    "Database connection failed",
+	"unauthorized":      "Authentication required, please log in first",
+	"forbidden":         "You do not have permission to perform this action",
+	"not_found":         "Resource not found",
+	"bad_request":       "Invalid request",
+	"too_many_requests": "Too many requests, please retry later",
+	"internal_error":    "Internal server error",
+
+	//请求格式和参数校验
+	"invalid_json":      "Malformed JSON request body",
+	"validation_failed": "Request validation failed",
+	"invalid_cursor":    "Invalid cursor",
+	"invalid_sort":      "Unsupported sort field",
+
+	//认证和权限
+	"invalid_token":             "Invalid token or token has expired",
+	"invalid_credentials":       "Invalid username or password",
+	"login_locked":              "Too many failed login attempts, please retry in {retry_after} seconds",
+	"invalid_refresh_token":     "Invalid refresh token or refresh token has expired",
+	"refresh_token_reused":      "Refresh token has already been used, please log in again",
+	"not_owner":                 "You do not own this resource",
+	"email_not_verified":        "Please verify your email address first",
+	"invalid_verification_link": "Invalid verification link or link has expired",
+	"invalid_reset_link":        "Invalid reset link or link has expired",
+
+	//资源不存在
+	"user_not_found":           "User not found",
+	"role_not_found":           "Role not found",
+	"post_not_found":           "Post not found",
+	"revision_not_found":       "Revision {number} not found",
+	"comment_not_found":        "Comment not found",
+	"parent_comment_not_found": "Parent comment not found",
+	"reaction_not_found":       "Reaction not found",
+	"tag_not_found":            "Tag not found",
+	"category_not_found":       "Category not found",
+	"media_not_found":          "Media not found",
+
+	//业务规则
+	"comments_closed":         "Post is not open for comments",
+	"parent_comment_mismatch": "Parent comment belongs to another post",
+	"reply_depth_exceeded":    "Maximum reply depth exceeded",
+	"name_conflict":           "Name is already in use",
+	"category_cycle":          "Category cannot be moved under itself or its subcategory",
+	"file_too_large":          "File exceeds {max_size} bytes",
+	"diff_too_large":          "Revisions with more than {max_lines} lines cannot be compared",
+	"unsupported_file_type":   "Unsupported file type {content_type}",
+
+	//字段校验，{param}为校验规则的参数
+	"field.required": "is required",
+	"field.invalid":  "is invalid",
+	"field.type":     "must be a {param}",
+	"field.min":      "must be at least {param}",
+	"field.max":      "must be at most {param}",
+	"field.len":      "must have length {param}",
+	"field.email":    "must be a valid email address",
+	"field.oneof":    "must be one of {param}",
+	"field.exists":   "refers to a record that does not exist",
+}
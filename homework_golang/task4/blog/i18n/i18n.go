@@ -0,0 +1,98 @@
+package i18n
+
+import (
+	"blog/cfg"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 支持的语言
+const (
+	ZhCN = "zh-CN"
+	En   = "en"
+)
+
+// 支持的语言列表，新增语言时同时在catalogs中加入消息目录
+var Locales = []string{ZhCN, En}
+
+// 各语言的消息目录：键为错误码，字段校验错误的键为"field."加校验规则；消息中的{name}由参数替换
+var catalogs = map[string]map[string]string{
+	ZhCN: zhCN,
+	En:   en,
+}
+
+// 默认语言，未配置或配置了不支持的语言时为简体中文
+func Default() string {
+	if locale := Match(cfg.CFG.I18n.DefaultLocale); locale != "" {
+		return locale
+	}
+	return ZhCN
+}
+
+// 把语言标签（如zh-Hans-CN、en_US）匹配到支持的语言，先完整匹配再按主语言匹配，都不支持时返回空字符串
+func Match(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if tag == "" {
+		return ""
+	}
+	for _, locale := range Locales {
+		if strings.EqualFold(tag, locale) {
+			return locale
+		}
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	for _, locale := range Locales {
+		if language, _, _ := strings.Cut(locale, "-"); strings.EqualFold(primary, language) {
+			return locale
+		}
+	}
+	return ""
+}
+
+// 按Accept-Language请求头（如"en-US,en;q=0.9,zh;q=0.8"）选择权重最高的支持的语言，没有时返回空字符串
+func Negotiate(header string) string {
+	type candidate struct {
+		tag     string
+		quality float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+		if quality > 0 {
+			candidates = append(candidates, candidate{strings.TrimSpace(tag), quality})
+		}
+	}
+	//权重相同时保持请求头中的先后顺序
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	for _, c := range candidates {
+		if locale := Match(c.tag); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// 读取消息并替换参数，语言中没有该消息时使用默认语言；都没有时ok为false
+func Message(locale, key string, params map[string]any) (string, bool) {
+	message, ok := catalogs[locale][key]
+	if !ok {
+		message, ok = catalogs[Default()][key]
+	}
+	if !ok {
+		return "", false
+	}
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", fmt.Sprint(value))
+	}
+	return message, true
+}
@@ -0,0 +1,62 @@
+package i18n
+
+var zhCN = map[string]string{
+	//通用错误
+	"database_error":    "数据库连接失败",
+	"unauthorized":      "用户认证失败，请先登录",
+	"forbidden":         "没有权限执行此操作",
+	"not_found":         "资源不存在",
+	"bad_request":       "请求参数错误",
+	"too_many_requests": "请求过于频繁，请稍后重试",
+	"internal_error":    "服务器内部错误",
+
+	//请求格式和参数校验
+	"invalid_json":      "请求体不是有效的JSON",
+	"validation_failed": "请求参数校验失败",
+	"invalid_cursor":    "翻页游标无效",
+	"invalid_sort":      "不支持的排序字段",
+
+	//认证和权限
+	"invalid_token":             "令牌无效或已过期",
+	"invalid_credentials":       "用户名或密码错误",
+	"login_locked":              "登录失败次数过多，请在{retry_after}秒后重试",
+	"invalid_refresh_token":     "刷新令牌无效或已过期",
+	"refresh_token_reused":      "刷新令牌已被使用，请重新登录",
+	"not_owner":                 "不能操作其他用户的资源",
+	"email_not_verified":        "请先验证邮箱",
+	"invalid_verification_link": "验证链接无效或已过期",
+	"invalid_reset_link":        "重置密码链接无效或已过期",
+
+	//资源不存在
+	"user_not_found":           "用户不存在",
+	"role_not_found":           "角色不存在",
+	"post_not_found":           "文章不存在",
+	"revision_not_found":       "修订{number}不存在",
+	"comment_not_found":        "评论不存在",
+	"parent_comment_not_found": "上级评论不存在",
+	"reaction_not_found":       "表态不存在",
+	"tag_not_found":            "标签不存在",
+	"category_not_found":       "分类不存在",
+	"media_not_found":          "文件不存在",
+
+	//业务规则
+	"comments_closed":         "文章未开放评论",
+	"parent_comment_mismatch": "上级评论不属于这篇文章",
+	"reply_depth_exceeded":    "超过评论回复的最大层数",
+	"name_conflict":           "名称已被使用",
+	"category_cycle":          "分类不能移到自身或子分类下面",
+	"file_too_large":          "文件超过{max_size}字节",
+	"diff_too_large":          "超过{max_lines}行的修订不能比较",
+	"unsupported_file_type":   "不支持的文件类型{content_type}",
+
+	//字段校验，{param}为校验规则的参数
+	"field.required": "不能为空",
+	"field.invalid":  "格式不正确",
+	"field.type":     "必须是{param}类型",
+	"field.min":      "不能小于{param}",
+	"field.max":      "不能大于{param}",
+	"field.len":      "长度必须为{param}",
+	"field.email":    "必须是有效的邮箱地址",
+	"field.oneof":    "必须是{param}之一",
+	"field.exists":   "引用的记录不存在",
+}
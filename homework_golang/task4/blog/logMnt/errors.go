@@ -0,0 +1,279 @@
+package logMnt
+
+import (
+	"blog/i18n"
+	"maps"
+	"net/http"
+)
+
+// 接口返回的错误：Code是稳定的错误码，客户端按错误码而不是消息处理错误；
+// 消息按错误码从i18n的消息目录中读取，其中的{name}由Params中的同名参数替换，Err是只写入日志的内部原因
+type AppError struct {
+	StatusCode int
+	Code       string
+	Params     map[string]any
+	Details    []FieldError
+	Err        error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// 错误码相同的AppError视为同一种错误，便于用errors.Is判断
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// 复制一份错误，预定义的错误不会被修改
+func (e *AppError) clone() *AppError {
+	copied := *e
+	copied.Params = maps.Clone(e.Params)
+	copied.Details = append([]FieldError(nil), e.Details...)
+	return &copied
+}
+
+// 附加内部原因，只写入日志，不返回给客户端
+func (e *AppError) Wrap(err error) *AppError {
+	copied := e.clone()
+	copied.Err = err
+	return copied
+}
+
+// 附加消息参数，参数同时返回给客户端
+func (e *AppError) With(name string, value any) *AppError {
+	copied := e.clone()
+	if copied.Params == nil {
+		copied.Params = map[string]any{}
+	}
+	copied.Params[name] = value
+	return copied
+}
+
+// 附加字段校验错误
+func (e *AppError) WithDetails(details ...FieldError) *AppError {
+	copied := e.clone()
+	copied.Details = append(copied.Details, details...)
+	return copied
+}
+
+// 错误在locale语言中的消息，消息目录中没有该错误码时返回错误码
+func (e *AppError) message(locale string) string {
+	if message, ok := i18n.Message(locale, e.Code, e.Params); ok {
+		return message
+	}
+	return e.Code
+}
+
+// 通用错误
+var (
+	ErrDatabaseConnection = &AppError{
+		StatusCode: http.StatusInternalServerError,
+		Code:       "database_error",
+	}
+
+	ErrUnauthorized = &AppError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "unauthorized",
+	}
+
+	ErrForbidden = &AppError{
+		StatusCode: http.StatusForbidden,
+		Code:       "forbidden",
+	}
+
+	ErrNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "not_found",
+	}
+
+	ErrBadRequest = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "bad_request",
+	}
+
+	ErrTooManyRequests = &AppError{
+		StatusCode: http.StatusTooManyRequests,
+		Code:       "too_many_requests",
+	}
+
+	ErrInternalServerError = &AppError{
+		StatusCode: http.StatusInternalServerError,
+		Code:       "internal_error",
+	}
+)
+
+// 请求格式和参数校验错误
+var (
+	ErrInvalidJSON = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_json",
+	}
+
+	ErrValidation = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "validation_failed",
+	}
+
+	ErrInvalidCursor = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_cursor",
+	}
+
+	ErrInvalidSort = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_sort",
+	}
+)
+
+// 认证和权限错误
+var (
+	ErrInvalidToken = &AppError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "invalid_token",
+	}
+
+	ErrInvalidCredentials = &AppError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "invalid_credentials",
+	}
+
+	ErrLoginLocked = &AppError{
+		StatusCode: http.StatusTooManyRequests,
+		Code:       "login_locked",
+	}
+
+	ErrInvalidRefreshToken = &AppError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "invalid_refresh_token",
+	}
+
+	ErrRefreshTokenReused = &AppError{
+		StatusCode: http.StatusUnauthorized,
+		Code:       "refresh_token_reused",
+	}
+
+	ErrNotOwner = &AppError{
+		StatusCode: http.StatusForbidden,
+		Code:       "not_owner",
+	}
+
+	ErrEmailNotVerified = &AppError{
+		StatusCode: http.StatusForbidden,
+		Code:       "email_not_verified",
+	}
+
+	ErrInvalidVerificationLink = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_verification_link",
+	}
+
+	ErrInvalidResetLink = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_reset_link",
+	}
+)
+
+// 资源不存在
+var (
+	ErrUserNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "user_not_found",
+	}
+
+	ErrRoleNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "role_not_found",
+	}
+
+	ErrPostNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "post_not_found",
+	}
+
+	ErrRevisionNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "revision_not_found",
+	}
+
+	ErrCommentNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "comment_not_found",
+	}
+
+	ErrParentCommentNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "parent_comment_not_found",
+	}
+
+	ErrReactionNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "reaction_not_found",
+	}
+
+	ErrTagNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "tag_not_found",
+	}
+
+	ErrCategoryNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "category_not_found",
+	}
+
+	ErrMediaNotFound = &AppError{
+		StatusCode: http.StatusNotFound,
+		Code:       "media_not_found",
+	}
+)
+
+// 业务规则错误
+var (
+	ErrCommentsClosed = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "comments_closed",
+	}
+
+	ErrParentCommentMismatch = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "parent_comment_mismatch",
+	}
+
+	ErrReplyDepthExceeded = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "reply_depth_exceeded",
+	}
+
+	ErrNameConflict = &AppError{
+		StatusCode: http.StatusConflict,
+		Code:       "name_conflict",
+	}
+
+	ErrCategoryCycle = &AppError{
+		StatusCode: http.StatusBadRequest,
+		Code:       "category_cycle",
+	}
+
+	ErrFileTooLarge = &AppError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Code:       "file_too_large",
+	}
+
+	ErrDiffTooLarge = &AppError{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Code:       "diff_too_large",
+	}
+
+	ErrUnsupportedFileType = &AppError{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Code:       "unsupported_file_type",
+	}
+)
@@ -10,51 +10,6 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-type AppError struct {
-	StatusCode int    `json:"code"`
-	Message    string `json:"message"`
-	Err        error  `json:"-"`
-}
-
-func (e *AppError) Error() string {
-	if e.Err != nil {
-		return e.Message + ": " + e.Err.Error()
-	}
-	return e.Message
-}
-
-var (
-	ErrDatabaseConnection = &AppError{
-		StatusCode: http.StatusInternalServerError,
-		Message:    "数据库连接失败",
-	}
-
-	ErrUnauthorized = &AppError{
-		StatusCode: http.StatusUnauthorized,
-		Message:    "用户认证失败",
-	}
-
-	ErrForbidden = &AppError{
-		StatusCode: http.StatusForbidden,
-		Message:    "没有权限执行此操作",
-	}
-
-	ErrNotFound = &AppError{
-		StatusCode: http.StatusNotFound,
-		Message:    "资源不存在",
-	}
-
-	ErrBadRequest = &AppError{
-		StatusCode: http.StatusBadRequest,
-		Message:    "请求参数错误",
-	}
-
-	ErrInternalServerError = &AppError{
-		StatusCode: http.StatusInternalServerError,
-		Message:    "服务器内部错误",
-	}
-)
-
 // 初始化zap日志配置
 func InitZapLogger() (*zap.Logger, error) {
 	// 开发环境配置
@@ -88,47 +43,48 @@ func LoggingMiddleware() gin.HandlerFunc {
 			zap.Int("status", c.Writer.Status()),
 			zap.Duration("duration", duration),
 			zap.String("ip", c.ClientIP()),
+			zap.String("request_id", RequestID(c)),
 		)
 	}
 }
 
-// 错误处理中间件
+// 错误处理中间件：处理函数通过c.Error返回错误，由这里记录日志并写入统一格式的错误响应
 func ErrorHandlingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 处理请求
 		c.Next()
 
 		// 检查是否有错误
-		if len(c.Errors) > 0 {
-			err := c.Errors.Last()
-			var appErr *AppError
+		if len(c.Errors) == 0 {
+			return
+		}
+		err := c.Errors.Last().Err
+		var appErr *AppError
 
-			// 判断错误类型
-			if errors.As(err, &appErr) {
-				// 已知应用错误
-				zap.L().Error("应用错误",
-					zap.String("message", appErr.Message),
-					zap.Int("status", appErr.StatusCode),
-					zap.Error(appErr.Err),
-					zap.String("path", c.Request.URL.Path),
-				)
-				c.JSON(appErr.StatusCode, gin.H{
-					"success": false,
-					"error":   appErr.Message,
-				})
-			} else {
-				// 未知错误
-				zap.L().Error("未知错误",
-					zap.Error(err),
-					zap.String("path", c.Request.URL.Path),
-				)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"error":   "服务器内部错误",
-				})
-			}
-			c.Abort()
+		// 判断错误类型，未知错误按服务器内部错误处理
+		if !errors.As(err, &appErr) {
+			appErr = ErrInternalServerError.Wrap(err)
+		}
+		fields := []zap.Field{
+			zap.String("code", appErr.Code),
+			zap.Int("status", appErr.StatusCode),
+			zap.Error(appErr.Err),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("request_id", RequestID(c)),
+		}
+		if len(appErr.Details) > 0 {
+			fields = append(fields, zap.Any("details", appErr.Details))
+		}
+		if appErr.StatusCode >= http.StatusInternalServerError {
+			zap.L().Error("应用错误", fields...)
+		} else {
+			zap.L().Warn("请求错误", fields...)
 		}
 
+		// 响应已经开始写出时（例如文件传输中途出错）不能再写入错误响应
+		if !c.Writer.Written() {
+			Fail(c, appErr)
+		}
+		c.Abort()
 	}
 }
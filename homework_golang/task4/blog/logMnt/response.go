@@ -0,0 +1,120 @@
+package logMnt
+
+import (
+	"blog/i18n"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 请求ID的请求头、响应头和上下文键，以及用户语言偏好的上下文键
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+	localeKey       = "locale"
+)
+
+// 客户端传入的请求ID只接受这些字符，避免写入日志和响应头时被注入内容
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// 统一的响应格式：成功时data为结果，失败时error为错误
+type Response struct {
+	Success bool       `json:"success"`
+	Data    any        `json:"data,omitempty"`
+	Error   *ErrorBody `json:"error,omitempty"`
+}
+
+// 返回给客户端的错误
+type ErrorBody struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Params    map[string]any `json:"params,omitempty"`
+	Details   []FieldDetail  `json:"details,omitempty"`
+	RequestID string         `json:"request_id"`
+}
+
+// 返回给客户端的字段错误
+type FieldDetail struct {
+	FieldError
+	Message string `json:"message"`
+}
+
+// 返回成功的响应
+func Success(c *gin.Context, status int, data any) {
+	c.JSON(status, Response{Success: true, Data: data})
+}
+
+// 返回错误响应，消息使用当前请求的语言
+func Fail(c *gin.Context, err *AppError) {
+	locale := Locale(c)
+	c.Header("Content-Language", locale)
+	c.JSON(err.StatusCode, Response{Error: errorBody(c, err, locale)})
+}
+
+func errorBody(c *gin.Context, err *AppError, locale string) *ErrorBody {
+	body := &ErrorBody{
+		Code:      err.Code,
+		Message:   err.message(locale),
+		Params:    err.Params,
+		RequestID: RequestID(c),
+	}
+	for _, detail := range err.Details {
+		body.Details = append(body.Details, FieldDetail{FieldError: detail, Message: detail.message(locale)})
+	}
+	return body
+}
+
+// 设置当前请求的语言，用于登录用户的语言偏好，优先于Accept-Language；为空或不支持时清除，按Accept-Language处理
+func SetLocale(c *gin.Context, locale string) {
+	c.Set(localeKey, i18n.Match(locale))
+}
+
+// 当前请求的语言：依次为用户的语言偏好、Accept-Language中支持的语言、默认语言
+func Locale(c *gin.Context) string {
+	if locale := c.GetString(localeKey); locale != "" {
+		return locale
+	}
+	if locale := i18n.Negotiate(c.GetHeader("Accept-Language")); locale != "" {
+		return locale
+	}
+	return i18n.Default()
+}
+
+// 当前请求的ID
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// 请求ID中间件：沿用客户端或网关传入的X-Request-ID，没有时生成一个，并写入响应头
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// 处理panic，返回统一格式的500响应
+func Recovery(c *gin.Context, recovered any) {
+	zap.L().Error("请求处理时发生panic",
+		zap.Any("panic", recovered),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("request_id", RequestID(c)),
+	)
+	Fail(c, ErrInternalServerError)
+	c.Abort()
+}
+
+// 未匹配到路由时返回统一格式的404
+func NoRoute(c *gin.Context) {
+	c.Error(ErrNotFound.With("path", c.Request.URL.Path))
+}
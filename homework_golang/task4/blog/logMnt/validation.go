@@ -0,0 +1,71 @@
+package logMnt
+
+import (
+	"blog/i18n"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 单个字段的校验错误，Code为校验规则（required、min、email等），Param为规则的参数
+type FieldError struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+	Param string `json:"param,omitempty"`
+}
+
+// 字段错误在locale语言中的说明，消息目录中没有该校验规则时使用"field.invalid"
+func (f FieldError) message(locale string) string {
+	params := map[string]any{"param": f.Param}
+	if message, ok := i18n.Message(locale, "field."+f.Code, params); ok {
+		return message
+	}
+	message, _ := i18n.Message(locale, "field.invalid", params)
+	return message
+}
+
+func init() {
+	//字段错误中使用json标签中的字段名
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// 单个字段校验失败，param为规则的参数，没有时传空字符串
+func InvalidField(field, code, param string) *AppError {
+	return ErrValidation.WithDetails(FieldError{Field: field, Code: code, Param: param})
+}
+
+// 将ShouldBindJSON等绑定请求参数时的错误转换为AppError，校验失败时列出每个字段的错误
+func BindError(err error) *AppError {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrs):
+		details := make([]FieldError, len(validationErrs))
+		for i, fe := range validationErrs {
+			details[i] = FieldError{Field: fe.Field(), Code: fe.Tag(), Param: fe.Param()}
+		}
+		return ErrValidation.WithDetails(details...).Wrap(err)
+	case errors.As(err, &typeErr):
+		return ErrValidation.WithDetails(FieldError{Field: typeErr.Field, Code: "type", Param: typeErr.Type.String()}).Wrap(err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrInvalidJSON.Wrap(err)
+	}
+	return ErrBadRequest.Wrap(err)
+}
@@ -0,0 +1,74 @@
+package mail
+
+import (
+	"blog/cfg"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// 邮件发送接口，生产环境使用SMTP，本地开发和测试时写入outbox目录
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// 按配置创建邮件发送器
+func New(c cfg.MailConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail from address %q: %w", c.From, err)
+	}
+
+	switch c.Driver {
+	case "smtp":
+		if c.SMTP.Host == "" {
+			return nil, fmt.Errorf("mail smtp host is required")
+		}
+		return &smtpMailer{from: from, config: c.SMTP}, nil
+	case "outbox", "":
+		dir := c.OutboxDir
+		if dir == "" {
+			dir = "outbox"
+		}
+		return &outboxMailer{from: from, dir: dir}, nil
+	}
+	return nil, fmt.Errorf("unsupported mail driver %q", c.Driver)
+}
+
+// 生成RFC 5322格式的邮件，主题按RFC 2047编码以支持中文
+func format(from *mail.Address, msg Message) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes(), nil
+}
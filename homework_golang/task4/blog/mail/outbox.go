@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 把邮件写成outbox目录下的.eml文件而不真正发送，便于本地调试邮件流程
+type outboxMailer struct {
+	from *mail.Address
+	dir  string
+}
+
+func (m *outboxMailer) Send(msg Message) error {
+	raw, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405.000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	//先写临时文件再改名，读取outbox的程序不会读到写了一半的邮件
+	tmp := filepath.Join(m.dir, "."+name)
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.dir, name))
+}
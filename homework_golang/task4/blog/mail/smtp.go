@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"blog/cfg"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// 通过SMTP服务器发送邮件，服务器支持时使用STARTTLS
+type smtpMailer struct {
+	from   *mail.Address
+	config cfg.SMTPConfig
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	raw, err := format(m.from, msg)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	port := m.config.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(int(port)))
+	return smtp.SendMail(addr, auth, m.from.Address, []string{to.Address}, raw)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"blog/export"
+	"blog/feed"
+	"flag"
+	"fmt"
+)
+
+// blog export-static [-out 目录] [-templates 目录] [-base-url 地址] [-page-size N]，把博客导出为静态站点
+func runExportStatic(args []string) error {
+	flags := flag.NewFlagSet("export-static", flag.ContinueOnError)
+	out := flags.String("out", "", "output directory (default export.output_dir)")
+	templates := flags.String("templates", "", "custom template directory (default export.template_dir)")
+	baseURL := flags.String("base-url", "", "site base URL (default site.base_url)")
+	pageSize := flags.Int("page-size", 0, "posts per index page (default export.page_size)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *baseURL != "" {
+		cfg.CFG.Site.BaseURL = *baseURL
+	}
+
+	db, err := data.InitDatabase(cfg.CFG.Db)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	store := data.NewStore(db)
+	feed.Init(store)
+	export.Init(store)
+	result, err := export.Export(export.Options{OutputDir: *out, TemplateDir: *templates, PageSize: *pageSize})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("exported %d posts, %d index pages and %d tags (%d files)\n", result.Posts, result.Pages, result.Tags, result.Files)
+	return nil
+}
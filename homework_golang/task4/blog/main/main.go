@@ -1,18 +1,49 @@
 package main
 
 import (
+	"blog/account"
+	"blog/auth"
 	"blog/cfg"
 	"blog/comment"
+	"blog/data"
+	"blog/feed"
 	"blog/logMnt"
+	"blog/mail"
+	"blog/media"
+	"blog/migration"
 	"blog/post"
+	"blog/ratelimit"
+	"blog/reaction"
+	"blog/search"
+	"blog/taxonomy"
+	"blog/token"
 	"blog/user"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// 关闭服务时等待进行中的请求完成的最长时间
+const shutdownTimeout = 10 * time.Second
+
+// 子命令，不带参数运行时启动服务
+var commands = map[string]func(args []string) error{
+	"migrate":        runMigrate,
+	"set-role":       runSetRole,
+	"search-reindex": runSearchReindex,
+	"export-static":  runExportStatic,
+	"media-gc":       runMediaGC,
+}
+
 func main() {
 	// 初始化zap日志
 	logger, err := logMnt.InitZapLogger()
@@ -24,66 +55,292 @@ func main() {
 	// 替换全局logger
 	zap.ReplaceGlobals(logger)
 
-	r := gin.Default()
+	// 执行子命令
+	if len(os.Args) > 1 {
+		command, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+		if err := command(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 初始化数据库连接池，整个进程共享
+	db, err := data.InitDatabase(cfg.CFG.Db)
+	if err != nil {
+		zap.L().Fatal("数据库连接失败", zap.Error(err))
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		zap.L().Fatal("数据库连接失败", zap.Error(err))
+	}
+	defer sqlDB.Close()
+
+	// 执行或检查数据库迁移
+	if cfg.CFG.Db.AutoMigrate {
+		ran, err := migration.Up(db)
+		if err != nil {
+			zap.L().Fatal("数据库迁移失败", zap.Error(err))
+		}
+		zap.L().Info("database migrated", zap.Int("applied", len(ran)))
+	} else if pending, err := migration.Pending(db); err != nil {
+		zap.L().Fatal("数据库迁移状态读取失败", zap.Error(err))
+	} else if pending > 0 {
+		zap.L().Warn("database has pending migrations, run \"blog migrate up\"", zap.Int("pending", pending))
+	}
+
+	// 向各业务模块注入仓库
+	store := data.NewStore(db)
+	auth.Init(store)
+	if err := token.Init(store); err != nil {
+		zap.L().Fatal("JWT签名密钥加载失败", zap.Error(err))
+	}
+	user.Init(store)
+	mailer, err := mail.New(cfg.CFG.Mail)
+	if err != nil {
+		zap.L().Fatal("邮件配置错误", zap.Error(err))
+	}
+	account.Init(store, mailer)
+	post.Init(store)
+	comment.Init(store)
+	reaction.Init(store)
+	search.Init(store)
+	taxonomy.Init(store)
+	feed.Init(store)
+	storage, err := media.NewStorage(cfg.CFG.Media)
+	if err != nil {
+		zap.L().Fatal("文件存储配置错误", zap.Error(err))
+	}
+	media.Init(store, storage)
+
+	// 定期清理过期的刷新令牌、吊销记录、登录失败计数和没有被引用的文件
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := token.PurgeExpired(); err != nil {
+				zap.L().Warn("failed to purge expired tokens", zap.Error(err))
+			}
+			if err := user.PurgeLoginFailures(); err != nil {
+				zap.L().Warn("failed to purge login failures", zap.Error(err))
+			}
+			if _, err := media.CollectGarbage(); err != nil {
+				zap.L().Warn("failed to collect orphaned media", zap.Error(err))
+			}
+		}
+	}()
+
+	// 定期发布到期的定时文章
+	go func() {
+		interval := cfg.CFG.Post.PublishInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		for ; ; time.Sleep(interval) {
+			if _, err := post.PublishDue(); err != nil {
+				zap.L().Warn("failed to publish scheduled posts", zap.Error(err))
+			}
+		}
+	}()
+
+	// 定期把缓冲的文章浏览数写入数据库
+	go func() {
+		interval := cfg.CFG.Post.ViewFlushInterval
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		for range time.Tick(interval) {
+			if _, err := post.FlushViews(); err != nil {
+				zap.L().Warn("failed to flush post views", zap.Error(err))
+			}
+		}
+	}()
 
-	// 移除默认的日志中间件，使用自定义日志中间件
-	r.Use(gin.Recovery()) // 恢复panic
+	// 不使用默认的日志和恢复中间件，使用自定义日志中间件和统一格式的错误响应
+	r := gin.New()
+	r.Use(logMnt.RequestIDMiddleware())
+	r.Use(gin.CustomRecovery(logMnt.Recovery)) // 恢复panic
 	r.Use(logMnt.LoggingMiddleware())
 	r.Use(logMnt.ErrorHandlingMiddleware())
+	r.NoRoute(logMnt.NoRoute)
+
+	// 只信任配置的反向代理传入的X-Forwarded-For，否则客户端可以伪造IP绕过按IP的限流和登录锁定
+	if err := r.SetTrustedProxies(cfg.CFG.Server.TrustedProxies); err != nil {
+		zap.L().Fatal("可信代理配置错误", zap.Error(err))
+	}
+
+	// 公开令牌验证公钥
+	r.GET("/.well-known/jwks.json", token.JWKS)
 
-	apiGroup := r.Group("/api")
+	// 订阅源和站点地图，按作者和标签分别提供订阅
+	r.GET("/feed.rss", feed.RSS)
+	r.GET("/feed.atom", feed.Atom)
+	r.GET("/authors/:username/feed.rss", feed.RSS)
+	r.GET("/authors/:username/feed.atom", feed.Atom)
+	r.GET("/tags/:slug/feed.rss", feed.RSS)
+	r.GET("/tags/:slug/feed.atom", feed.Atom)
+	r.GET("/sitemap.xml", feed.Sitemap)
+
+	// 上传的文件和图片缩略图
+	r.GET("/media/:hash", media.Serve)
+	r.GET("/media/:hash/thumbnail", media.Thumbnail)
+
+	apiGroup := r.Group("/api", ratelimit.Middleware("api"))
 	{
 		//用户
 		apiUserGroup := apiGroup.Group("/users")
 		{
+			//注册、登录和刷新令牌按IP限流，防止暴力破解和批量注册
+			authLimit := ratelimit.Middleware("auth")
 			//用户注册
-			apiUserGroup.POST("/register", user.Register)
+			apiUserGroup.POST("/register", authLimit, user.Register)
 			//用户登录
-			apiUserGroup.POST("/login", user.Login)
+			apiUserGroup.POST("/login", authLimit, user.Login)
+			//刷新令牌
+			apiUserGroup.POST("/refresh", authLimit, user.Refresh)
+			//验证邮箱
+			apiUserGroup.POST("/verify-email", authLimit, account.VerifyEmail)
+			//重新发送验证邮件
+			apiUserGroup.POST("/verify-email/send", authLimit, user.JWTAuthMiddleware(), account.SendVerification)
+			//申请重置密码
+			apiUserGroup.POST("/password/forgot", authLimit, account.ForgotPassword)
+			//重置密码
+			apiUserGroup.POST("/password/reset", authLimit, account.ResetPassword)
+			//退出登录
+			apiUserGroup.POST("/logout", user.JWTAuthMiddleware(), user.Logout)
+			//最近的登录记录
+			apiUserGroup.GET("/logins", user.JWTAuthMiddleware(), user.GetLogins)
+			//设置接口消息的语言偏好
+			apiUserGroup.PUT("/locale", user.JWTAuthMiddleware(), user.UpdateLocale)
+			//修改用户角色
+			apiUserGroup.PUT("/role", user.JWTAuthMiddleware(), auth.RequirePermission("user:manage"), user.UpdateRole)
 
 			//文章
 			apiUserPostGroup := apiUserGroup.Group("/posts")
 			{
-				//用户认证
-				apiUserPostGroup.Use(user.JWTAuthMiddleware())
+				//读取文章列表，作者登录后可用status查询自己未发布的文章
+				apiUserPostGroup.GET("/all/get", user.OptionalJWTAuthMiddleware(), post.GetPosts)
+
+				//读取单篇文章
+				apiUserPostGroup.GET("/get", user.OptionalJWTAuthMiddleware(), post.GetPost)
+
+				//全文搜索文章
+				apiUserPostGroup.GET("/search", search.Search)
+
+				//用户认证，作者取自token中的用户身份；写接口按用户限流
+				apiUserPostAuthGroup := apiUserPostGroup.Group("", user.JWTAuthMiddleware(), ratelimit.Middleware("write"))
 				{
 					//创建文章
-					apiUserPostGroup.POST("/create", post.CreatePost)
+					apiUserPostAuthGroup.POST("/create", auth.RequirePermission("post:create"), account.RequireVerified(), post.CreatePost)
+
+					//更新文章
+					apiUserPostAuthGroup.PUT("/update", post.UpdatePost)
+
+					//删除文章
+					apiUserPostAuthGroup.DELETE("/delete", post.DeletePost)
+
+					//对文章表态和取消表态
+					apiUserPostAuthGroup.POST("/reactions", reaction.AddPostReaction)
+					apiUserPostAuthGroup.DELETE("/reactions", reaction.RemovePostReaction)
 				}
 
-				//读取文章列表
-				apiUserPostGroup.GET("/all/get", post.GetPosts)
+				//修订历史，作者和有post:update:any权限的用户可查看和恢复
+				apiUserPostRevisionGroup := apiUserPostGroup.Group("/revisions", user.JWTAuthMiddleware())
+				{
+					//修订列表
+					apiUserPostRevisionGroup.GET("", post.GetRevisions)
 
-				//读取单篇文章
-				apiUserPostGroup.GET("/get", post.GetPost)
+					//比较两个修订
+					apiUserPostRevisionGroup.GET("/diff", post.GetRevisionDiff)
+
+					//恢复到某个修订
+					apiUserPostRevisionGroup.POST("/restore", ratelimit.Middleware("write"), post.RestoreRevision)
+				}
+
+				//上传文章中使用的图片和附件
+				apiUserPostGroup.POST("/media/upload", user.JWTAuthMiddleware(), ratelimit.Middleware("write"), auth.RequirePermission("media:upload"), media.Upload)
+
+				//标签
+				apiUserPostTagGroup := apiUserPostGroup.Group("/tags")
+				{
+					//标签云，文章列表按标签过滤时传tag_id
+					apiUserPostTagGroup.GET("", taxonomy.GetTags)
+
+					//管理标签
+					apiUserPostTagAuthGroup := apiUserPostTagGroup.Group("", user.JWTAuthMiddleware(), auth.RequirePermission("tag:manage"))
+					{
+						apiUserPostTagAuthGroup.POST("/create", taxonomy.CreateTag)
+						apiUserPostTagAuthGroup.PUT("/update", taxonomy.UpdateTag)
+						apiUserPostTagAuthGroup.DELETE("/delete", taxonomy.DeleteTag)
+					}
+				}
 
-				//更新文章
-				apiUserPostGroup.PUT("/update", post.UpdatePost)
+				//分类
+				apiUserPostCategoryGroup := apiUserPostGroup.Group("/categories")
+				{
+					//分类树，文章列表按分类（包括子分类）过滤时传category_id
+					apiUserPostCategoryGroup.GET("", taxonomy.GetCategories)
 
-				//删除文章
-				apiUserPostGroup.DELETE("/delete", post.DeletePost)
+					//管理分类
+					apiUserPostCategoryAuthGroup := apiUserPostCategoryGroup.Group("", user.JWTAuthMiddleware(), auth.RequirePermission("category:manage"))
+					{
+						apiUserPostCategoryAuthGroup.POST("/create", taxonomy.CreateCategory)
+						apiUserPostCategoryAuthGroup.PUT("/update", taxonomy.UpdateCategory)
+						apiUserPostCategoryAuthGroup.DELETE("/delete", taxonomy.DeleteCategory)
+					}
+				}
 
 				//评论
 				apiUserPostCommentGroup := apiUserPostGroup.Group("/comments")
 				{
-					//用户认证
-					apiUserPostCommentGroup.Use(user.JWTAuthMiddleware())
+					//读取某篇文章的所有评论列表
+					apiUserPostCommentGroup.GET("/all/get", user.OptionalJWTAuthMiddleware(), comment.GetComments)
+
+					//读取某篇文章的评论树
+					apiUserPostCommentGroup.GET("/tree", user.OptionalJWTAuthMiddleware(), comment.GetCommentTree)
+
+					//用户认证，写接口按用户限流
+					apiUserPostCommentAuthGroup := apiUserPostCommentGroup.Group("", user.JWTAuthMiddleware(), ratelimit.Middleware("write"))
 					{
 						//对文章发表评论
-						apiUserPostCommentGroup.POST("/create", comment.CreateComment)
-					}
+						apiUserPostCommentAuthGroup.POST("/create", auth.RequirePermission("comment:create"), account.RequireVerified(), comment.CreateComment)
 
-					//读取某篇文章的所有评论列表
-					apiUserPostCommentGroup.GET("/all/get", comment.GetComments)
+						//修改评论
+						apiUserPostCommentAuthGroup.PUT("/update", comment.UpdateComment)
+
+						//删除评论
+						apiUserPostCommentAuthGroup.DELETE("/delete", comment.DeleteComment)
+
+						//对评论表态和取消表态
+						apiUserPostCommentAuthGroup.POST("/reactions", reaction.AddCommentReaction)
+						apiUserPostCommentAuthGroup.DELETE("/reactions", reaction.RemoveCommentReaction)
+					}
 				}
 			}
 		}
 	}
 
 	zap.L().Info("start server", zap.Uint("port", cfg.CFG.Server.Port))
-	err = r.Run(":" + strconv.Itoa(int(cfg.CFG.Server.Port))) // 监听并在 0.0.0.0:8080 上启动服务
-	if err != nil {
-		panic(err.Error())
-		os.Exit(1)
+	srv := &http.Server{Addr: ":" + strconv.Itoa(int(cfg.CFG.Server.Port)), Handler: r} // 监听并在 0.0.0.0:8080 上启动服务
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err.Error())
+		}
+	}()
+
+	// 收到退出信号后等待进行中的请求完成，再把缓冲的浏览数写入数据库，避免部署或重启时丢失
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	zap.L().Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		zap.L().Warn("failed to shut down server gracefully", zap.Error(err))
+	}
+	if _, err := post.FlushViews(); err != nil {
+		zap.L().Warn("failed to flush post views", zap.Error(err))
 	}
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"blog/media"
+	"fmt"
+)
+
+// blog media-gc，立即清理超过media.orphan_ttl仍没有被文章引用的文件
+func runMediaGC(args []string) error {
+	db, err := data.InitDatabase(cfg.CFG.Db)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	storage, err := media.NewStorage(cfg.CFG.Media)
+	if err != nil {
+		return err
+	}
+	media.Init(data.NewStore(db), storage)
+	count, err := media.CollectGarbage()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed %d orphaned files\n", count)
+	return nil
+}
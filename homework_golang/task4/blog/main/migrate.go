@@ -0,0 +1,82 @@
+package main
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"blog/migration"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = "usage: blog migrate up | down [N] | status"
+
+// blog migrate up | down [N] | status
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := data.InitDatabase(cfg.CFG.Db)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	switch args[0] {
+	case "up":
+		ran, err := migration.Up(db)
+		for _, m := range ran {
+			fmt.Printf("up   %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ran) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return nil
+
+	case "down":
+		//默认回滚一个迁移
+		n := 1
+		if len(args) > 1 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid migration count %q", args[1])
+			}
+		}
+		ran, err := migration.Down(db, n)
+		for _, m := range ran {
+			fmt.Printf("down %04d %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ran) == 0 {
+			fmt.Println("no migrations to roll back")
+		}
+		return nil
+
+	case "status":
+		statuses, err := migration.List(db)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-40s %s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
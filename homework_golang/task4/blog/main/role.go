@@ -0,0 +1,41 @@
+package main
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"errors"
+	"fmt"
+)
+
+// blog set-role <username> <role>，用于在没有管理员时指定第一个管理员
+func runSetRole(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: blog set-role <username> <role>")
+	}
+
+	db, err := data.InitDatabase(cfg.CFG.Db)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	store := data.NewStore(db)
+	user, err := store.Users.FindByUsername(args[0])
+	if err != nil {
+		return fmt.Errorf("user %q: %w", args[0], err)
+	}
+	role, err := store.Roles.FindByName(args[1])
+	if err != nil {
+		return fmt.Errorf("role %q: %w", args[1], err)
+	}
+	if err := store.Users.UpdateRole(user, role); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %s is now %s\n", user.Username, role.Name)
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"blog/search"
+	"fmt"
+)
+
+// blog search-reindex，重建全部文章的搜索索引
+func runSearchReindex(args []string) error {
+	db, err := data.InitDatabase(cfg.CFG.Db)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	search.Init(data.NewStore(db))
+	count, err := search.Reindex()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("indexed %d posts\n", count)
+	return nil
+}
@@ -0,0 +1,336 @@
+package media
+
+import (
+	"blog/auth"
+	"blog/cfg"
+	"blog/data"
+	"blog/logMnt"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 未配置时的上传限制和缩略图大小
+const (
+	defaultMaxSize       = 10 << 20
+	defaultThumbnailSize = 320
+	defaultOrphanTTL     = 24 * time.Hour
+)
+
+// 未配置时允许上传的文件类型
+var defaultAllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"}
+
+// 每次垃圾回收最多处理的文件数
+const gcBatch = 100
+
+var ErrMediaNotFound = errors.New("media not found")
+
+var (
+	store   *data.Store
+	storage Storage
+)
+
+// 注入媒体模块依赖的仓库和文件存储
+func Init(s *data.Store, st Storage) {
+	store = s
+	storage = st
+}
+
+func maxSize() int64 {
+	if cfg.CFG.Media.MaxSize > 0 {
+		return cfg.CFG.Media.MaxSize
+	}
+	return defaultMaxSize
+}
+
+func allowed(contentType string) bool {
+	types := cfg.CFG.Media.AllowedTypes
+	if len(types) == 0 {
+		types = defaultAllowedTypes
+	}
+	return slices.Contains(types, contentType)
+}
+
+// 上传一个文件（multipart表单字段file），内容相同的文件只保存一份，图片同时生成缩略图
+func Upload(c *gin.Context) {
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	//限制请求体大小，留出multipart边界和其他字段的余量
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize()+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(logMnt.ErrFileTooLarge.With("max_size", maxSize()).Wrap(err))
+			return
+		}
+		c.Error(logMnt.InvalidField("file", "required", "").Wrap(err))
+		return
+	}
+	if header.Size > maxSize() {
+		c.Error(logMnt.ErrFileTooLarge.With("max_size", maxSize()))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("upload file: %w", err)))
+		return
+	}
+	defer file.Close()
+
+	//按内容检测类型，不信任客户端声明的Content-Type和扩展名
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("upload file: %w", err)))
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if !allowed(contentType) {
+		c.Error(logMnt.ErrUnsupportedFileType.With("content_type", contentType))
+		return
+	}
+
+	//计算内容哈希，已有相同内容的文件时直接返回
+	hasher := sha256.New()
+	if _, err = file.Seek(0, io.SeekStart); err == nil {
+		_, err = io.Copy(hasher, file)
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("upload file: %w", err)))
+		return
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if existing, err := store.Media.FindByHash(hash); err == nil {
+		zap.L().Info("deduplicate media", zap.Uint("media_id", existing.ID), zap.String("hash", hash), zap.Uint("user_id", userID))
+		logMnt.Success(c, http.StatusOK, existing)
+		return
+	}
+
+	media := &data.Media{
+		Hash:        hash,
+		Key:         objectKey(hash, ""),
+		Filename:    path.Base(strings.ReplaceAll(header.Filename, "\\", "/")),
+		ContentType: contentType,
+		Size:        header.Size,
+		UserID:      userID,
+	}
+	thumb, err := prepare(file, media)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("upload file: %w", err)))
+		return
+	}
+
+	//先插入记录再写文件：清理同一内容的旧记录时插入会等待清理完成，写入的文件不会被清理删除；写文件失败时记录一并回滚
+	err = store.Transaction(func(tx *data.Store) error {
+		if err := tx.Media.Create(media); err != nil {
+			return err
+		}
+		return put(file, media, thumb)
+	})
+	if err != nil {
+		//并发上传相同内容时唯一索引冲突，返回先保存的记录
+		if existing, findErr := store.Media.FindByHash(hash); findErr == nil {
+			logMnt.Success(c, http.StatusOK, existing)
+			return
+		}
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("upload file: %w", err)))
+		return
+	}
+
+	zap.L().Info("upload media",
+		zap.Uint("media_id", media.ID),
+		zap.String("hash", hash),
+		zap.String("content_type", contentType),
+		zap.Int64("size", media.Size),
+		zap.Uint("user_id", userID),
+	)
+	logMnt.Success(c, http.StatusCreated, media)
+}
+
+// 读取图片尺寸并生成缩略图，填入media并返回缩略图内容，由put写入存储；不是图片时返回nil，读取尺寸或生成缩略图失败只记录日志
+func prepare(file io.ReadSeeker, media *data.Media) ([]byte, error) {
+	if !strings.HasPrefix(media.ContentType, "image/") {
+		return nil, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	width, height, err := imageSize(file)
+	if err != nil {
+		zap.L().Warn("failed to read image size", zap.String("hash", media.Hash), zap.Error(err))
+		return nil, nil
+	}
+	media.Width, media.Height = width, height
+
+	size := cfg.CFG.Media.ThumbnailSize
+	if size <= 0 {
+		size = defaultThumbnailSize
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	thumb, thumbType, err := thumbnail(file, width, height, size)
+	if err != nil {
+		zap.L().Warn("failed to create thumbnail", zap.String("hash", media.Hash), zap.Error(err))
+		return nil, nil
+	}
+	media.ThumbnailKey = objectKey(media.Hash, "_thumb.jpg")
+	if thumbType == "image/png" {
+		media.ThumbnailKey = objectKey(media.Hash, "_thumb.png")
+	}
+	return thumb, nil
+}
+
+// 把文件和prepare生成的缩略图写入存储
+func put(file io.ReadSeeker, media *data.Media, thumb []byte) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := storage.Put(media.Key, file); err != nil {
+		return err
+	}
+	if media.ThumbnailKey == "" {
+		return nil
+	}
+	return storage.Put(media.ThumbnailKey, bytes.NewReader(thumb))
+}
+
+// 读取文件：/media/:hash，内容由哈希确定，可以长期缓存
+func Serve(c *gin.Context) {
+	media, ok := findMedia(c)
+	if !ok {
+		return
+	}
+	serve(c, media.Key, media.ContentType, media.Size, `"`+media.Hash+`"`)
+}
+
+// 读取图片的缩略图：/media/:hash/thumbnail
+func Thumbnail(c *gin.Context) {
+	media, ok := findMedia(c)
+	if !ok {
+		return
+	}
+	if media.ThumbnailKey == "" {
+		c.Error(logMnt.ErrMediaNotFound)
+		return
+	}
+	contentType := "image/jpeg"
+	if strings.HasSuffix(media.ThumbnailKey, ".png") {
+		contentType = "image/png"
+	}
+	serve(c, media.ThumbnailKey, contentType, -1, `"`+media.Hash+`-thumb"`)
+}
+
+func findMedia(c *gin.Context) (*data.Media, bool) {
+	media, err := store.Media.FindByHash(c.Param("hash"))
+	if err != nil {
+		c.Error(logMnt.ErrMediaNotFound)
+		return nil, false
+	}
+	return media, true
+}
+
+func serve(c *gin.Context, key, contentType string, size int64, etag string) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	reader, err := storage.Open(key)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("read file: %w", err)))
+		return
+	}
+	defer reader.Close()
+
+	//图片以外的文件作为附件下载，避免浏览器把上传的内容当作页面执行
+	headers := map[string]string{"X-Content-Type-Options": "nosniff"}
+	if !strings.HasPrefix(contentType, "image/") {
+		headers["Content-Disposition"] = "attachment"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, reader, headers)
+}
+
+// 查找文章要引用的文件，有不存在的ID时返回ErrMediaNotFound
+func ResolveMedia(ids []uint) ([]data.Media, error) {
+	if len(ids) == 0 {
+		return []data.Media{}, nil
+	}
+	media, err := store.Media.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := map[uint]bool{}
+	for _, m := range media {
+		found[m.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: %d", ErrMediaNotFound, id)
+		}
+	}
+	return media, nil
+}
+
+// 清理上传超过orphan_ttl仍没有被任何文章引用的文件，返回清理的文件数
+func CollectGarbage() (int, error) {
+	ttl := cfg.CFG.Media.OrphanTTL
+	if ttl <= 0 {
+		ttl = defaultOrphanTTL
+	}
+	before := time.Now().Add(-ttl)
+
+	count := 0
+	for {
+		orphans, err := store.Media.ListOrphans(before, gcBatch)
+		if err != nil {
+			return count, err
+		}
+		for i := range orphans {
+			//锁定记录后重新确认没有被引用，删除记录和文件后才提交，期间重新上传相同内容会等待删除完成
+			err := store.Media.Delete(&orphans[i], func() {
+				for _, key := range []string{orphans[i].Key, orphans[i].ThumbnailKey} {
+					if key == "" {
+						continue
+					}
+					if err := storage.Delete(key); err != nil {
+						zap.L().Warn("failed to delete media file", zap.String("key", key), zap.Error(err))
+					}
+				}
+			})
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return count, err
+			}
+			zap.L().Info("collect orphaned media", zap.Uint("media_id", orphans[i].ID), zap.String("hash", orphans[i].Hash))
+			count++
+		}
+		if len(orphans) < gcBatch {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package media
+
+import (
+	"blog/cfg"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 文件存储接口，键由内容哈希生成；目前提供本地文件系统实现，可替换为S3兼容的对象存储
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// 按配置创建文件存储
+func NewStorage(c cfg.MediaConfig) (Storage, error) {
+	switch c.Driver {
+	case "local", "":
+		dir := c.Dir
+		if dir == "" {
+			dir = "uploads"
+		}
+		return &localStorage{dir: dir}, nil
+	}
+	return nil, fmt.Errorf("unsupported media driver %q", c.Driver)
+}
+
+// 保存在本地目录中的文件存储
+type localStorage struct {
+	dir string
+}
+
+// 键只能由存储自己生成，这里仍拒绝跳出存储目录的路径
+func (s *localStorage) path(key string) (string, error) {
+	if key == "" || !fs.ValidPath(key) {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *localStorage) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	//先写临时文件再改名，读取时不会读到写了一半的文件
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+hex.EncodeToString(suffix))
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *localStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// 删除不存在的文件不算错误
+func (s *localStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// 按哈希生成存储键，用哈希前两位分目录，避免单个目录中文件过多
+func objectKey(hash, suffix string) string {
+	return strings.Join([]string{hash[:2], hash + suffix}, "/")
+}
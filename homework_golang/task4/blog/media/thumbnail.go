@@ -0,0 +1,65 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// 解码前限制图片的像素数，防止小文件解压成超大图片
+const maxPixels = 50_000_000
+
+var errImageTooLarge = errors.New("image dimensions are too large")
+
+// 读取图片的宽高
+func imageSize(r io.Reader) (int, int, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// 生成宽高都不超过size的缩略图，保持宽高比，小图不放大；
+// PNG和GIF可能有透明部分，缩略图为PNG，其他为JPEG
+func thumbnail(r io.Reader, width, height, size int) ([]byte, string, error) {
+	if width*height > maxPixels {
+		return nil, "", errImageTooLarge
+	}
+	src, format, err := image.Decode(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	w, h := width, height
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(height*size/width, 1)
+		} else {
+			w, h = max(width*size/height, 1), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == "png" || format == "gif" {
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// 初始表结构，与原先AutoMigrate生成的结构一致，已有数据库执行时不会重复建表
+type user0001 struct {
+	gorm.Model
+	Username string `gorm:"unique;not null"`
+	Password string `gorm:"not null"`
+	Email    string `gorm:"unique;not null"`
+}
+
+func (user0001) TableName() string { return "users" }
+
+type post0001 struct {
+	gorm.Model
+	Title   string `gorm:"not null"`
+	Content string `gorm:"not null"`
+	UserID  uint
+}
+
+func (post0001) TableName() string { return "posts" }
+
+type comment0001 struct {
+	gorm.Model
+	Content string `gorm:"not null"`
+	UserID  uint
+	PostID  uint
+}
+
+func (comment0001) TableName() string { return "comments" }
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "create_users_posts_comments",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&user0001{}, &post0001{}, &comment0001{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&comment0001{}, &post0001{}, &user0001{})
+		},
+	})
+}
@@ -0,0 +1,101 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// 角色与权限
+type role0002 struct {
+	ID          uint             `gorm:"primaryKey"`
+	Name        string           `gorm:"size:32;unique;not null"`
+	Permissions []permission0002 `gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionID"`
+}
+
+func (role0002) TableName() string { return "roles" }
+
+type permission0002 struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:64;unique;not null"`
+}
+
+func (permission0002) TableName() string { return "permissions" }
+
+type user0002 struct {
+	RoleID uint `gorm:"not null;default:0"`
+}
+
+func (user0002) TableName() string { return "users" }
+
+// 内置角色及其权限
+var rolePermissions0002 = []struct {
+	role        string
+	permissions []string
+}{
+	{"admin", []string{
+		"post:create", "post:update:own", "post:update:any", "post:delete:own", "post:delete:any",
+		"comment:create", "user:manage",
+	}},
+	{"editor", []string{
+		"post:create", "post:update:own", "post:update:any", "post:delete:own", "post:delete:any",
+		"comment:create",
+	}},
+	{"author", []string{
+		"post:create", "post:update:own", "post:delete:own",
+		"comment:create",
+	}},
+	{"reader", []string{
+		"comment:create",
+	}},
+}
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "create_roles_and_permissions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&permission0002{}, &role0002{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&user0002{}, "RoleID"); err != nil {
+				return err
+			}
+
+			//写入内置角色和权限
+			permissions := map[string]*permission0002{}
+			roleIDs := map[string]uint{}
+			for _, rp := range rolePermissions0002 {
+				role := role0002{Name: rp.role}
+				for _, name := range rp.permissions {
+					p, ok := permissions[name]
+					if !ok {
+						p = &permission0002{Name: name}
+						if err := tx.Create(p).Error; err != nil {
+							return err
+						}
+						permissions[name] = p
+					}
+					role.Permissions = append(role.Permissions, *p)
+				}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+				roleIDs[rp.role] = role.ID
+			}
+
+			//已有用户保持原有能力，归为作者
+			return tx.Model(&user0002{}).Where("role_id = 0").Update("role_id", roleIDs["author"]).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&user0002{}, "RoleID"); err != nil {
+				return err
+			}
+			//按外键依赖顺序逐个删除
+			for _, table := range []interface{}{"role_permissions", &role0002{}, &permission0002{}} {
+				if err := tx.Migrator().DropTable(table); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// 全文搜索倒排索引，由search包维护，可用blog search-reindex重建
+type postTerm0003 struct {
+	ID     uint   `gorm:"primaryKey"`
+	PostID uint   `gorm:"not null;index"`
+	Term   string `gorm:"size:64;not null;index"`
+	Weight int    `gorm:"not null"`
+}
+
+func (postTerm0003) TableName() string { return "post_terms" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "create_post_terms",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&postTerm0003{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&postTerm0003{})
+		},
+	})
+}
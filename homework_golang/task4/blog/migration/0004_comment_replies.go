@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// 评论回复：parent_id指向被回复的评论，depth为嵌套层级（顶层为0）
+type comment0004 struct {
+	ParentID *uint `gorm:"index"`
+	Depth    int   `gorm:"not null;default:0"`
+}
+
+func (comment0004) TableName() string { return "comments" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "add_comment_replies",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&comment0004{}, "ParentID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&comment0004{}, "ParentID"); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&comment0004{}, "Depth")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&comment0004{}, "ParentID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&comment0004{}, "Depth"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&comment0004{}, "ParentID")
+		},
+	})
+}
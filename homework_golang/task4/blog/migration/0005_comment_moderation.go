@@ -0,0 +1,56 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 评论编辑时间
+type comment0005 struct {
+	EditedAt *time.Time
+}
+
+func (comment0005) TableName() string { return "comments" }
+
+// 评论编辑和删除权限：所有角色可处理自己的评论，编辑和管理员可处理任意评论
+var commentPermissions0005 = map[string][]string{
+	"admin":  {"comment:update:own", "comment:update:any", "comment:delete:own", "comment:delete:any"},
+	"editor": {"comment:update:own", "comment:update:any", "comment:delete:own", "comment:delete:any"},
+	"author": {"comment:update:own", "comment:delete:own"},
+	"reader": {"comment:update:own", "comment:delete:own"},
+}
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "add_comment_moderation",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&comment0005{}, "EditedAt"); err != nil {
+				return err
+			}
+			return grantPermissions(tx, commentPermissions0005)
+		},
+		Down: func(tx *gorm.DB) error {
+			err := dropPermissions(tx, []string{
+				"comment:update:own", "comment:update:any", "comment:delete:own", "comment:delete:any",
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&comment0005{}, "EditedAt"); err != nil {
+				return err
+			}
+			//sqlite删除列时会重建表并丢失索引，补回之前的迁移创建的索引，使回滚后的结构与0004一致
+			if !tx.Migrator().HasIndex(&comment0001{}, "DeletedAt") {
+				if err := tx.Migrator().CreateIndex(&comment0001{}, "DeletedAt"); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&comment0004{}, "ParentID") {
+				return tx.Migrator().CreateIndex(&comment0004{}, "ParentID")
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,42 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 刷新令牌和令牌吊销列表
+type refreshToken0006 struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	FamilyID  string    `gorm:"size:64;not null;index"`
+	TokenHash string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (refreshToken0006) TableName() string { return "refresh_tokens" }
+
+type revokedToken0006 struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenID   string    `gorm:"size:64;unique;not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (revokedToken0006) TableName() string { return "revoked_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "create_refresh_and_revoked_tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&refreshToken0006{}, &revokedToken0006{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&revokedToken0006{}, &refreshToken0006{})
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 登录记录和登录失败计数
+type loginAttempt0007 struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    *uint  `gorm:"index"`
+	Username  string `gorm:"size:64;not null"`
+	IP        string `gorm:"size:64;not null;index"`
+	UserAgent string `gorm:"size:255"`
+	Success   bool   `gorm:"not null"`
+	Reason    string `gorm:"size:32"`
+	CreatedAt time.Time
+}
+
+func (loginAttempt0007) TableName() string { return "login_attempts" }
+
+type loginFailure0007 struct {
+	Subject      string `gorm:"primaryKey;size:191"`
+	Failures     int    `gorm:"not null"`
+	Lockouts     int    `gorm:"not null"`
+	LockedUntil  *time.Time
+	LastFailedAt time.Time `gorm:"not null;index"`
+}
+
+func (loginFailure0007) TableName() string { return "login_failures" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "create_login_attempts_and_failures",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&loginAttempt0007{}, &loginFailure0007{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&loginFailure0007{}, &loginAttempt0007{})
+		},
+	})
+}
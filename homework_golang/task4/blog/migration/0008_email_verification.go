@@ -0,0 +1,31 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 邮箱验证时间，为空表示邮箱未验证
+type user0008 struct {
+	EmailVerifiedAt *time.Time
+}
+
+func (user0008) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "add_user_email_verified_at",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&user0008{}, "EmailVerifiedAt"); err != nil {
+				return err
+			}
+			//只有新注册的账号需要验证邮箱，已有的账号视为注册时已验证，开启require_verified后不受影响
+			return tx.Exec("UPDATE users SET email_verified_at = created_at").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&user0008{}, "EmailVerifiedAt")
+		},
+	})
+}
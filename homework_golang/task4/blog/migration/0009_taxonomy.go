@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 标签、分类及其与文章的关联表
+type tag0009 struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"size:64;not null"`
+	Slug      string `gorm:"size:64;unique;not null"`
+	CreatedAt time.Time
+}
+
+func (tag0009) TableName() string { return "tags" }
+
+type category0009 struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"size:64;not null"`
+	Slug      string `gorm:"size:64;unique;not null"`
+	ParentID  *uint  `gorm:"index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (category0009) TableName() string { return "categories" }
+
+type postTag0009 struct {
+	PostID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey;index"`
+}
+
+func (postTag0009) TableName() string { return "post_tags" }
+
+type postCategory0009 struct {
+	PostID     uint `gorm:"primaryKey"`
+	CategoryID uint `gorm:"primaryKey;index"`
+}
+
+func (postCategory0009) TableName() string { return "post_categories" }
+
+// 标签和分类管理权限：编辑和管理员可以管理，作者给自己的文章打标签时自动创建标签
+var taxonomyPermissions0009 = map[string][]string{
+	"admin":  {"tag:manage", "category:manage"},
+	"editor": {"tag:manage", "category:manage"},
+}
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "create_tags_and_categories",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&tag0009{}, &category0009{}, &postTag0009{}, &postCategory0009{}); err != nil {
+				return err
+			}
+			return grantPermissions(tx, taxonomyPermissions0009)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropPermissions(tx, []string{"tag:manage", "category:manage"}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&postCategory0009{}, &postTag0009{}, &category0009{}, &tag0009{})
+		},
+	})
+}
@@ -0,0 +1,50 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 文章状态和发布时间，已有的文章视为在创建时发布
+type post0010 struct {
+	Status    string     `gorm:"size:16;not null;default:published;index"`
+	PublishAt *time.Time `gorm:"index"`
+}
+
+func (post0010) TableName() string { return "posts" }
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "add_post_status",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&post0010{}, "Status"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&post0010{}, "PublishAt"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&post0010{}, "Status"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&post0010{}, "PublishAt"); err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE posts SET status = ?, publish_at = created_at", "published").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range []string{"Status", "PublishAt"} {
+				if tx.Migrator().HasIndex(&post0010{}, field) {
+					if err := tx.Migrator().DropIndex(&post0010{}, field); err != nil {
+						return err
+					}
+				}
+				if err := tx.Migrator().DropColumn(&post0010{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 文章修订，已有的文章以当前内容作为第1个修订
+type postRevision0011 struct {
+	ID           uint   `gorm:"primaryKey"`
+	PostID       uint   `gorm:"not null;uniqueIndex:idx_post_revisions_number"`
+	Number       int    `gorm:"not null;uniqueIndex:idx_post_revisions_number"`
+	Title        string `gorm:"not null"`
+	Content      string `gorm:"not null"`
+	UserID       uint   `gorm:"not null"`
+	RestoredFrom *int
+	CreatedAt    time.Time
+}
+
+func (postRevision0011) TableName() string { return "post_revisions" }
+
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "create_post_revisions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&postRevision0011{}); err != nil {
+				return err
+			}
+			return tx.Exec(`INSERT INTO post_revisions (post_id, number, title, content, user_id, created_at)
+				SELECT id, 1, title, content, user_id, updated_at FROM posts`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&postRevision0011{})
+		},
+	})
+}
@@ -0,0 +1,35 @@
+package migration
+
+import "gorm.io/gorm"
+
+// 文章内容格式和渲染后的HTML缓存，已有文章按Markdown处理，HTML在首次读取时渲染
+type post0012 struct {
+	Format      string `gorm:"size:16;not null;default:markdown"`
+	HTML        string
+	HTMLVersion int `gorm:"not null;default:0"`
+}
+
+func (post0012) TableName() string { return "posts" }
+
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "add_post_format",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"Format", "HTML", "HTMLVersion"} {
+				if err := tx.Migrator().AddColumn(&post0012{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, field := range []string{"Format", "HTML", "HTMLVersion"} {
+				if err := tx.Migrator().DropColumn(&post0012{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
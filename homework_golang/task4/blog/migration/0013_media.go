@@ -0,0 +1,57 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 上传的文件及其与文章的关联表
+type media0013 struct {
+	ID           uint   `gorm:"primaryKey"`
+	Hash         string `gorm:"size:64;unique;not null"`
+	Key          string `gorm:"not null"`
+	ThumbnailKey string
+	Filename     string `gorm:"not null"`
+	ContentType  string `gorm:"size:128;not null"`
+	Size         int64  `gorm:"not null"`
+	Width        int
+	Height       int
+	UserID       uint `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func (media0013) TableName() string { return "media" }
+
+type postMedia0013 struct {
+	PostID  uint `gorm:"primaryKey"`
+	MediaID uint `gorm:"primaryKey;index"`
+}
+
+func (postMedia0013) TableName() string { return "post_media" }
+
+// 能写文章的角色可以上传文件
+var mediaPermissions0013 = map[string][]string{
+	"admin":  {"media:upload"},
+	"editor": {"media:upload"},
+	"author": {"media:upload"},
+}
+
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "create_media",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&media0013{}, &postMedia0013{}); err != nil {
+				return err
+			}
+			return grantPermissions(tx, mediaPermissions0013)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropPermissions(tx, []string{"media:upload"}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&postMedia0013{}, &media0013{})
+		},
+	})
+}
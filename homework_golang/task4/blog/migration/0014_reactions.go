@@ -0,0 +1,45 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 文章和评论的表态，同一用户对同一对象的每种表态只能有一个
+type reaction0014 struct {
+	ID         uint   `gorm:"primaryKey"`
+	TargetType string `gorm:"size:16;not null;uniqueIndex:idx_reactions_unique,priority:1"`
+	TargetID   uint   `gorm:"not null;uniqueIndex:idx_reactions_unique,priority:2"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_reactions_unique,priority:3"`
+	Kind       string `gorm:"size:16;not null;uniqueIndex:idx_reactions_unique,priority:4"`
+	CreatedAt  time.Time
+}
+
+func (reaction0014) TableName() string { return "reactions" }
+
+// 文章浏览数，由内存中的计数定期批量累加
+type post0014 struct {
+	ViewCount int64 `gorm:"not null;default:0"`
+}
+
+func (post0014) TableName() string { return "posts" }
+
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "create_reactions",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&reaction0014{}); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&post0014{}, "ViewCount")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&post0014{}, "ViewCount"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&reaction0014{})
+		},
+	})
+}
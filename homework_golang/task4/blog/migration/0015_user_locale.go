@@ -0,0 +1,23 @@
+package migration
+
+import "gorm.io/gorm"
+
+// 用户的语言偏好，为空时按请求的Accept-Language
+type user0015 struct {
+	Locale string `gorm:"size:16;not null;default:''"`
+}
+
+func (user0015) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "add_user_locale",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&user0015{}, "Locale")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&user0015{}, "Locale")
+		},
+	})
+}
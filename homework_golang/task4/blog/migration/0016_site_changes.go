@@ -0,0 +1,28 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 各类内容最近一次变化的时间，订阅源据此生成Last-Modified
+type siteChange0016 struct {
+	Name      string    `gorm:"primaryKey;size:64"`
+	ChangedAt time.Time `gorm:"not null"`
+}
+
+func (siteChange0016) TableName() string { return "site_changes" }
+
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "create_site_changes",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&siteChange0016{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&siteChange0016{})
+		},
+	})
+}
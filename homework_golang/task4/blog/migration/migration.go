@@ -0,0 +1,162 @@
+package migration
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 一次有版本号的结构变更，Up升级，Down回滚
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// 迁移记录表，每执行一个迁移插入一行
+type schemaMigration struct {
+	Version   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// 迁移状态
+type Status struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// 已注册的迁移，按版本号升序排列
+var migrations []Migration
+
+// 注册迁移，由各迁移文件的init调用
+func register(m Migration) {
+	for _, existing := range migrations {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("duplicate migration version %d", m.Version))
+		}
+	}
+	migrations = append(migrations, m)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// 读取已执行的迁移版本，只读不修改结构，版本表不存在时视为全部未执行
+func applied(db *gorm.DB) (map[uint]schemaMigration, error) {
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return map[uint]schemaMigration{}, nil
+	}
+
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	done := make(map[uint]schemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// 按版本号顺序执行所有未执行的迁移，返回本次执行的迁移
+func Up(db *gorm.DB) ([]Migration, error) {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range migrations {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d %s up: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// 从最新版本开始回滚n个已执行的迁移，返回本次回滚的迁移
+func Down(db *gorm.DB, n int) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for i := len(migrations) - 1; i >= 0 && len(ran) < n; i-- {
+		m := migrations[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: m.Version}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d %s down: %w", m.Version, m.Name, err)
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// 列出所有迁移及其执行时间，未执行的AppliedAt为nil
+func List(db *gorm.DB) ([]Status, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		status := Status{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// 未执行的迁移数量
+func Pending(db *gorm.DB) (int, error) {
+	statuses, err := List(db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
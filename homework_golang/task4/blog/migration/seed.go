@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+// 为已有角色授予权限，权限不存在时创建
+func grantPermissions(tx *gorm.DB, grants map[string][]string) error {
+	for roleName, names := range grants {
+		var role role0002
+		if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
+			return err
+		}
+		for _, name := range names {
+			permission := permission0002{Name: name}
+			if err := tx.Where("name = ?", name).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(&permission); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 删除权限及其授权关系
+func dropPermissions(tx *gorm.DB, names []string) error {
+	var ids []uint
+	if err := tx.Model(&permission0002{}).Where("name IN ?", names).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&permission0002{}).Error
+}
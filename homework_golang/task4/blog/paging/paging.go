@@ -0,0 +1,107 @@
+package paging
+
+import (
+	"blog/data"
+	"blog/logMnt"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 将查询列表时的错误转换为AppError：游标无效或排序字段不支持时返回400，其他错误按数据库错误处理
+func Error(err error) *logMnt.AppError {
+	switch {
+	case errors.Is(err, data.ErrInvalidCursor):
+		return logMnt.ErrInvalidCursor.Wrap(err)
+	case errors.Is(err, data.ErrInvalidSort):
+		return logMnt.ErrInvalidSort.Wrap(err)
+	}
+	return logMnt.ErrDatabaseConnection.Wrap(err)
+}
+
+// 从查询参数解析列表条件：
+// limit、cursor、sort、order(asc|desc，默认desc)、author、post_id、parent_id、tag_id、category_id、
+// status(draft|scheduled|published|archived|all，默认只查询已发布的文章)、from、to(RFC3339或2006-01-02)
+func ParseQuery(c *gin.Context) (data.ListQuery, error) {
+	q := data.ListQuery{
+		Cursor: c.Query("cursor"),
+		Sort:   c.DefaultQuery("sort", "created_at"),
+		Desc:   true,
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return q, logMnt.InvalidField("limit", "min", "1").Wrap(fmt.Errorf("invalid limit %q", limit))
+		}
+		q.Limit = n
+	}
+
+	switch order := c.DefaultQuery("order", "desc"); order {
+	case "asc":
+		q.Desc = false
+	case "desc":
+	default:
+		return q, logMnt.InvalidField("order", "oneof", "asc desc").Wrap(fmt.Errorf("invalid order %q", order))
+	}
+
+	switch q.Status = c.Query("status"); q.Status {
+	case "", "all", data.PostDraft, data.PostScheduled, data.PostPublished, data.PostArchived:
+	default:
+		return q, logMnt.InvalidField("status", "oneof", "all draft scheduled published archived").Wrap(fmt.Errorf("invalid status %q", q.Status))
+	}
+
+	var err error
+	if q.AuthorID, err = parseID(c, "author"); err != nil {
+		return q, err
+	}
+	if q.PostID, err = parseID(c, "post_id"); err != nil {
+		return q, err
+	}
+	if q.ParentID, err = parseID(c, "parent_id"); err != nil {
+		return q, err
+	}
+	if q.TagID, err = parseID(c, "tag_id"); err != nil {
+		return q, err
+	}
+	if q.CategoryID, err = parseID(c, "category_id"); err != nil {
+		return q, err
+	}
+	if q.From, err = parseTime(c, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTime(c, "to"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+func parseID(c *gin.Context, name string) (uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, logMnt.InvalidField(name, "invalid", "").Wrap(fmt.Errorf("invalid %s %q", name, value))
+	}
+	return uint(id), nil
+}
+
+func parseTime(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return nil, logMnt.InvalidField(name, "invalid", "").Wrap(fmt.Errorf("invalid %s %q", name, value))
+	}
+	return &t, nil
+}
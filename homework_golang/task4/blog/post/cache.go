@@ -0,0 +1,123 @@
+package post
+
+import (
+	"blog/cache"
+	"blog/data"
+	"blog/logMnt"
+	"blog/reaction"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 缓存的内容及其生成时间，生成时间作为响应的Last-Modified
+type cached[T any] struct {
+	Value    T         `json:"value"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// 先读缓存，未命中时调用load并写入缓存
+func fromCache[T any](key string, load func() (T, error)) (T, time.Time, error) {
+	if value, ok := cache.Get(key); ok {
+		var entry cached[T]
+		if err := json.Unmarshal(value, &entry); err == nil {
+			return entry.Value, entry.CachedAt, nil
+		}
+	}
+
+	entry := cached[T]{CachedAt: time.Now().UTC()}
+	var err error
+	if entry.Value, err = load(); err != nil {
+		return entry.Value, entry.CachedAt, err
+	}
+	if value, err := json.Marshal(&entry); err == nil {
+		cache.Set(key, value)
+	}
+	return entry.Value, entry.CachedAt, nil
+}
+
+// 读取文章详情，包括渲染后的HTML、表态数量和浏览数，不包括当前用户做过的表态
+func loadPost(id uint) (*data.Post, time.Time, error) {
+	post, modified, err := fromCache(cache.PostKey(id), func() (data.Post, error) {
+		post, err := store.Posts.FindByID(id)
+		if err != nil {
+			return data.Post{}, err
+		}
+		ensureRendered(post)
+		posts := []data.Post{*post}
+		fillViews(posts)
+		if err := reaction.FillPosts(posts, 0); err != nil {
+			return data.Post{}, err
+		}
+		return posts[0], nil
+	})
+	return &post, modified, err
+}
+
+// 读取一页文章，key为cache.PostListKey生成的缓存键
+func loadPosts(key string, query data.ListQuery) (*data.Page[data.Post], time.Time, error) {
+	page, modified, err := fromCache(key, func() (data.Page[data.Post], error) {
+		page, err := store.Posts.List(query)
+		if err != nil {
+			return data.Page[data.Post]{}, err
+		}
+		for i := range page.Items {
+			ensureRendered(&page.Items[i])
+		}
+		fillViews(page.Items)
+		if err := reaction.FillPosts(page.Items, 0); err != nil {
+			return data.Page[data.Post]{}, err
+		}
+		return *page, nil
+	})
+	return &page, modified, err
+}
+
+// 以统一的响应格式返回value，带上由响应内容计算的强ETag和Last-Modified；
+// 请求的If-None-Match或If-Modified-Since表明客户端的副本仍然有效时返回304，不支持按字节范围返回
+func serveJSON(c *gin.Context, modified time.Time, value any) {
+	encoded, err := json.Marshal(logMnt.Response{Success: true, Data: value})
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("encode response: %w", err)))
+		return
+	}
+
+	sum := sha256.Sum256(encoded)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	//登录用户看到的表态和未发布文章因人而异，客户端每次都要重新验证
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Vary", "Authorization")
+	if notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", encoded)
+}
+
+// 客户端的副本是否仍然有效：带If-None-Match时只比较ETag，否则比较If-Modified-Since（精确到秒）
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
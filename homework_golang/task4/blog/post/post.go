@@ -1,39 +1,106 @@
 package post
 
 import (
+	"blog/auth"
+	"blog/cache"
 	"blog/data"
+	"blog/feed"
 	"blog/logMnt"
+	"blog/media"
+	"blog/paging"
+	"blog/reaction"
+	"blog/render"
+	"blog/search"
+	"blog/taxonomy"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+var store *data.Store
+
+// 注入文章模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 创建和更新文章的请求体：format为markdown或plain，见applyFormat；status和publish_at见applyStatus；tags为标签名，不存在的标签自动创建；category_ids为分类ID；
+// media_ids为文章引用的已上传文件ID。更新时省略tags、category_ids或media_ids表示不修改，传空数组表示清空
+type postInput struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	Tags        *[]string  `json:"tags"`
+	CategoryIDs *[]uint    `json:"category_ids"`
+	MediaIDs    *[]uint    `json:"media_ids"`
+}
+
 func CreatePost(c *gin.Context) {
 	//获取文章信息
-	var post data.Post
-	if err := c.ShouldBindJSON(&post); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid create post parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var input postInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(logMnt.BindError(err))
 		return
 	}
+	post := data.Post{Title: input.Title, Content: input.Content, Tags: []data.Tag{}, Categories: []data.Category{}, Media: []data.Media{}}
 
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//确定文章状态，未指定时立即发布
+	if err := applyStatus(&input, &post); err != nil {
+		c.Error(inputError(err))
 		return
 	}
 
-	//插入文章信息
-	if err := db.Create(&post).Error; err != nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to create post"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
+	//按内容格式渲染HTML
+	if err := applyFormat(&input, &post, post.Content); err != nil {
+		c.Error(inputError(err))
+		return
+	}
+
+	//解析标签、分类和引用的文件，与文章一起写入
+	if !resolveTaxonomy(c, &input, &post) || !resolveMedia(c, &input, &post) {
+		return
+	}
+
+	//作者取自认证身份，忽略请求体中的user_id
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+		return
+	}
+	post.UserID = userID
+
+	//创建新标签并插入文章信息，同时保存第1个修订
+	err = store.Transaction(func(tx *data.Store) error {
+		tags, err := taxonomy.SaveTags(tx, post.Tags)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+		if err := tx.Posts.Create(&post); err != nil {
+			return err
+		}
+		return tx.Revisions.Create(newRevision(&post, userID))
+	})
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("create post: %w", err)))
 		return
 	}
 
+	//建立搜索索引（未发布的文章不建索引），失败不影响文章创建，可用blog search-reindex补建
+	if err = search.IndexPost(&post); err != nil {
+		zap.L().Warn("failed to index post", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+	cache.InvalidatePosts()
+	feed.Touch()
+
 	zap.L().Info("create post",
 		zap.Uint("post_id", post.ID),
 		zap.String("title", post.Title),
@@ -41,40 +108,69 @@ func CreatePost(c *gin.Context) {
 		zap.Time("created_at", post.CreatedAt),
 	)
 	//返回创建文章成功的信息给客户端
-	c.JSON(http.StatusCreated, gin.H{
-		"message":    "Post created successfully",
+	logMnt.Success(c, http.StatusCreated, gin.H{
 		"id":         post.ID,
 		"title":      post.Title,
 		"content":    post.Content,
+		"format":     post.Format,
+		"html":       post.HTML,
+		"status":     post.Status,
+		"publish_at": post.PublishAt,
+		"tags":       post.Tags,
+		"categories": post.Categories,
+		"media":      post.Media,
 		"created_at": post.CreatedAt.Format(time.RFC3339),
 	})
 }
 
 func GetPosts(c *gin.Context) {
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid get posts parameter"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//获取分页、排序和过滤条件
+	query, err := paging.ParseQuery(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	//post_id和parent_id只用于评论列表
+	if query.PostID != 0 {
+		c.Error(logMnt.InvalidField("post_id", "invalid", ""))
+		return
+	}
+	if query.ParentID != 0 {
+		c.Error(logMnt.InvalidField("parent_id", "invalid", ""))
+		return
+	}
+
+	//未发布的文章只有作者本人可见
+	query.ViewerID = auth.ViewerID(c)
 
-	//从数据表获取所有文章信息
-	var storedPosts []data.Post
-	if err := db.Find(&storedPosts).Error; err != nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get posts"})
+	//从缓存或数据表获取一页文章信息；只查询已发布的文章时结果与当前用户无关，所有用户共用缓存
+	viewerID := query.ViewerID
+	if query.Status == "" {
+		viewerID = 0
+	}
+	page, modified, err := loadPosts(cache.PostListKey(viewerID, c.Request.URL.Query().Encode()), query)
+	if err != nil {
+		c.Error(paging.Error(fmt.Errorf("get posts: %w", err)))
 		return
 	}
+	if query.ViewerID != 0 {
+		if err := reaction.FillReacted(page.Items, query.ViewerID); err != nil {
+			c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get posts: %w", err)))
+			return
+		}
+	}
 
 	zap.L().Info("get post list",
-		zap.Int("count", len(storedPosts)),
+		zap.Int("count", len(page.Items)),
+		zap.Int64("total", page.Total),
 	)
-	//将所有文章信息发送给客户端
-	c.JSON(http.StatusOK, gin.H{
-		"message": "All posts found successfully",
-		"count":   len(storedPosts),
-		"data":    storedPosts,
+	//将一页文章信息和翻页游标发送给客户端
+	serveJSON(c, modified, gin.H{
+		"count":       len(page.Items),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+		"items":       page.Items,
 	})
 }
 
@@ -82,25 +178,29 @@ func GetPost(c *gin.Context) {
 	//获取文章信息
 	var post data.Post
 	if err := c.ShouldBindJSON(&post); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid get post parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(logMnt.BindError(err))
 		return
 	}
 
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//从缓存或数据表获取文章信息
+	viewerID := auth.ViewerID(c)
+	storedPost, modified, err := loadPost(post.ID)
+	if err != nil || !storedPost.VisibleTo(viewerID) {
+		c.Error(logMnt.ErrPostNotFound)
 		return
 	}
 
-	//从数据表获取文章信息
-	var storedPost data.Post
-	if err := db.Where("id = ?", post.ID).First(&storedPost).Error; err != nil {
-		zap.L().Error(logMnt.ErrNotFound.Message, zap.String("error", "Post not found"))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
-		return
+	//只统计已公开文章的浏览，作者预览草稿不计入；缓存中的浏览数在缓存过期后更新
+	if storedPost.Status == data.PostPublished || storedPost.Status == data.PostArchived {
+		recordView(storedPost.ID)
+	}
+	if viewerID != 0 {
+		posts := []data.Post{*storedPost}
+		if err := reaction.FillReacted(posts, viewerID); err != nil {
+			c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get post: %w", err)))
+			return
+		}
+		storedPost = &posts[0]
 	}
 
 	zap.L().Info("get post details",
@@ -110,51 +210,102 @@ func GetPost(c *gin.Context) {
 		zap.Time("created_at", storedPost.CreatedAt),
 	)
 	//将文章信息发送给客户端
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Post found successfully",
-		"data":    storedPost,
-	})
+	serveJSON(c, modified, storedPost)
 }
 
 func UpdatePost(c *gin.Context) {
 	//获取文章信息
-	var updatePost data.Post
+	var updatePost postInput
 	if err := c.ShouldBindJSON(&updatePost); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid update post parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(logMnt.BindError(err))
 		return
 	}
 
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//查询文章并检查是否存在
+	post, err := store.Posts.FindByID(updatePost.ID)
+	if err != nil {
+		c.Error(logMnt.ErrPostNotFound)
 		return
 	}
 
-	//查询文章并检查是否存在
-	var post data.Post
-	if err := db.First(&post, updatePost.ID).Error; err != nil {
-		zap.L().Error(logMnt.ErrNotFound.Message, zap.String("error", "Post not found"))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	//权限检查：作者可更新自己的文章，编辑和管理员可更新任意文章
+	if !authorize(c, post.UserID, "post:update") {
 		return
 	}
 
-	//权限检查：只有文章作者才能更新
-	if post.UserID != updatePost.UserID {
-		zap.L().Error(logMnt.ErrForbidden.Message, zap.String("error", "User does not match post user"))
-		c.JSON(http.StatusForbidden, gin.H{"error": "User does not match post user"})
+	//解析新的格式、状态、标签、分类和引用的文件，未传的保持不变
+	tagged := data.Post{
+		Content: post.Content, Format: post.Format, HTMLVersion: post.HTMLVersion,
+		Status: post.Status, PublishAt: post.PublishAt, Tags: post.Tags, Categories: post.Categories, Media: post.Media,
+	}
+	content := updatePost.Content
+	if content == "" {
+		content = post.Content
+	}
+	if err := applyFormat(&updatePost, &tagged, content); err != nil {
+		c.Error(inputError(err))
+		return
+	}
+	if err := applyStatus(&updatePost, &tagged); err != nil {
+		c.Error(inputError(err))
+		return
+	}
+	if !resolveTaxonomy(c, &updatePost, &tagged) || !resolveMedia(c, &updatePost, &tagged) {
 		return
 	}
 
-	//将文章更新至数据库表
-	if err := db.Model(&post).Updates(&updatePost).Error; err != nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to update post"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	//只允许修改标题、内容、格式、状态、标签、分类和引用的文件，作者不可变更；标题或内容有变化时保存新的修订
+	oldTitle, oldContent := post.Title, post.Content
+	changes := data.Post{
+		Title: updatePost.Title, Content: updatePost.Content, Format: tagged.Format, HTML: tagged.HTML, HTMLVersion: tagged.HTMLVersion,
+		Status: tagged.Status, PublishAt: tagged.PublishAt,
+	}
+	err = store.Transaction(func(tx *data.Store) error {
+		if err := tx.Posts.Update(post, &changes); err != nil {
+			return err
+		}
+		if updatePost.Tags != nil {
+			tags, err := taxonomy.SaveTags(tx, tagged.Tags)
+			if err != nil {
+				return err
+			}
+			if err := tx.Posts.SetTags(post, tags); err != nil {
+				return err
+			}
+		}
+		if updatePost.CategoryIDs != nil {
+			if err := tx.Posts.SetCategories(post, tagged.Categories); err != nil {
+				return err
+			}
+		}
+		if updatePost.MediaIDs != nil {
+			if err := tx.Posts.SetMedia(post, tagged.Media); err != nil {
+				return err
+			}
+		}
+		if post.Title == oldTitle && post.Content == oldContent {
+			return nil
+		}
+		return tx.Revisions.Create(newRevision(post, userID))
+	})
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update post: %w", err)))
 		return
 	}
 
+	//更新搜索索引，文章撤回或归档时移出索引
+	if err := search.IndexPost(post); err != nil {
+		zap.L().Warn("failed to index post", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+	cache.InvalidatePosts(post.ID)
+	feed.Touch()
+
 	zap.L().Info("update post",
 		zap.Uint("post_id", post.ID),
 		zap.String("title", post.Title),
@@ -163,11 +314,17 @@ func UpdatePost(c *gin.Context) {
 		zap.Time("updated_at", post.UpdatedAt),
 	)
 	//将更新后的文章信息返回给客户端
-	c.JSON(http.StatusOK, gin.H{
-		"message":    "Post updated successfully",
+	logMnt.Success(c, http.StatusOK, gin.H{
 		"id":         post.ID,
 		"title":      post.Title,
 		"content":    post.Content,
+		"format":     post.Format,
+		"html":       post.HTML,
+		"status":     post.Status,
+		"publish_at": post.PublishAt,
+		"tags":       post.Tags,
+		"categories": post.Categories,
+		"media":      post.Media,
 		"created_at": post.CreatedAt.Format(time.RFC3339),
 		"updated_at": post.UpdatedAt.Format(time.RFC3339),
 	})
@@ -177,41 +334,35 @@ func DeletePost(c *gin.Context) {
 	//获取文章信息
 	var deletePost data.Post
 	if err := c.ShouldBindJSON(&deletePost); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid delete post parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrDatabaseConnection.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+		c.Error(logMnt.BindError(err))
 		return
 	}
 
 	//查询文章并检查是否存在
-	var post data.Post
-	if err := db.Unscoped().Where("id = ?", deletePost.ID).First(&post).Error; err != nil {
-		zap.L().Error(logMnt.ErrNotFound.Message, zap.String("error", "Post not found"))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	post, err := store.Posts.FindByID(deletePost.ID)
+	if err != nil {
+		c.Error(logMnt.ErrPostNotFound)
 		return
 	}
 
-	//权限检查：只有文章作者才能删除
-	if post.UserID != deletePost.UserID {
-		zap.L().Error(logMnt.ErrForbidden.Message, zap.String("error", "User does not match post user"))
-		c.JSON(http.StatusForbidden, gin.H{"error": "User does not match post user"})
+	//权限检查：作者可删除自己的文章，编辑和管理员可删除任意文章
+	if !authorize(c, post.UserID, "post:delete") {
 		return
 	}
 
 	//将文章从数据库表中删除
-	if err := db.Unscoped().Model(&post).Delete(&deletePost).Error; err != nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to delete post"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
+	if err := store.Posts.Delete(post); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("delete post: %w", err)))
 		return
 	}
 
+	//删除搜索索引
+	if err := search.RemovePost(post.ID); err != nil {
+		zap.L().Warn("failed to remove post from index", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+	cache.InvalidatePosts(post.ID)
+	feed.Touch()
+
 	zap.L().Info("delete post",
 		zap.Uint("post_id", post.ID),
 		zap.String("title", post.Title),
@@ -220,8 +371,7 @@ func DeletePost(c *gin.Context) {
 		zap.Time("deleted_at", post.DeletedAt.Time),
 	)
 	//将删除了的文章信息返回给客户端
-	c.JSON(http.StatusOK, gin.H{
-		"message":    "Post deleted successfully",
+	logMnt.Success(c, http.StatusOK, gin.H{
 		"id":         post.ID,
 		"title":      post.Title,
 		"content":    post.Content,
@@ -229,3 +379,81 @@ func DeletePost(c *gin.Context) {
 		"deleted_at": post.DeletedAt.Time.Format(time.RFC3339),
 	})
 }
+
+// 把请求中的标签名和分类ID解析到post上，参数有误时写入错误响应并返回false；新标签在保存文章的事务中创建
+func resolveTaxonomy(c *gin.Context, input *postInput, post *data.Post) bool {
+	if input.Tags != nil {
+		tags, err := taxonomy.ParseTags(*input.Tags)
+		if errors.Is(err, taxonomy.ErrInvalidName) || errors.Is(err, taxonomy.ErrTooManyTags) {
+			c.Error(inputError(err))
+			return false
+		}
+		if err != nil {
+			c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("save post tags: %w", err)))
+			return false
+		}
+		post.Tags = tags
+	}
+
+	if input.CategoryIDs != nil {
+		categories, err := taxonomy.ResolveCategories(*input.CategoryIDs)
+		if errors.Is(err, taxonomy.ErrCategoryNotFound) {
+			c.Error(inputError(err))
+			return false
+		}
+		if err != nil {
+			c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("save post categories: %w", err)))
+			return false
+		}
+		post.Categories = categories
+	}
+	return true
+}
+
+// 把请求中的文件ID解析到post上，参数有误时写入错误响应并返回false
+func resolveMedia(c *gin.Context, input *postInput, post *data.Post) bool {
+	if input.MediaIDs == nil {
+		return true
+	}
+	files, err := media.ResolveMedia(*input.MediaIDs)
+	if errors.Is(err, media.ErrMediaNotFound) {
+		c.Error(inputError(err))
+		return false
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("save post media: %w", err)))
+		return false
+	}
+	post.Media = files
+	return true
+}
+
+// 把请求参数有误的错误转换为对应字段的校验错误，其他错误（如渲染失败）按服务器错误处理
+func inputError(err error) *logMnt.AppError {
+	switch {
+	case errors.Is(err, ErrInvalidStatus):
+		return logMnt.InvalidField("status", "oneof", "draft scheduled published archived").Wrap(err)
+	case errors.Is(err, ErrInvalidPublishAt):
+		return logMnt.InvalidField("publish_at", "invalid", "").Wrap(err)
+	case errors.Is(err, render.ErrUnknownFormat):
+		return logMnt.InvalidField("format", "oneof", render.Markdown+" "+render.Plain).Wrap(err)
+	case errors.Is(err, taxonomy.ErrInvalidName):
+		return logMnt.InvalidField("tags", "invalid", "").Wrap(err)
+	case errors.Is(err, taxonomy.ErrTooManyTags):
+		return logMnt.InvalidField("tags", "max", strconv.Itoa(taxonomy.MaxPostTags)).Wrap(err)
+	case errors.Is(err, taxonomy.ErrCategoryNotFound):
+		return logMnt.InvalidField("category_ids", "exists", "").Wrap(err)
+	case errors.Is(err, media.ErrMediaNotFound):
+		return logMnt.InvalidField("media_ids", "exists", "").Wrap(err)
+	}
+	return logMnt.ErrInternalServerError.Wrap(fmt.Errorf("render post: %w", err))
+}
+
+// 检查当前用户能否对文章执行操作，不能则写入错误响应并返回false
+func authorize(c *gin.Context, ownerID uint, action string) bool {
+	if err := auth.Authorize(c, ownerID, action); err != nil {
+		auth.RespondError(c, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package post
+
+import (
+	"blog/auth"
+	"blog/auth/authtest"
+	"blog/cfg"
+	"blog/data"
+	"blog/feed"
+	"blog/render"
+	"blog/search"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 测试用的假仓库，只实现处理函数用到的方法，其他方法调用时panic
+type fakePosts struct {
+	data.PostRepo
+	posts map[uint]*data.Post
+}
+
+func (f *fakePosts) FindByID(id uint) (*data.Post, error) {
+	post, ok := f.posts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *post
+	return &copied, nil
+}
+
+func (f *fakePosts) Create(post *data.Post) error {
+	post.ID = uint(len(f.posts) + 1)
+	f.posts[post.ID] = post
+	return nil
+}
+
+func (f *fakePosts) Update(post *data.Post, changes *data.Post) error {
+	if changes.Title != "" {
+		post.Title = changes.Title
+	}
+	if changes.Content != "" {
+		post.Content = changes.Content
+	}
+	post.Status, post.PublishAt = changes.Status, changes.PublishAt
+	f.posts[post.ID] = post
+	return nil
+}
+
+func (f *fakePosts) Delete(post *data.Post) error {
+	delete(f.posts, post.ID)
+	return nil
+}
+
+type fakeRevisions struct {
+	data.RevisionRepo
+}
+
+func (fakeRevisions) Create(revision *data.PostRevision) error {
+	return nil
+}
+
+type fakeSearch struct {
+	data.SearchRepo
+}
+
+func (fakeSearch) ReplaceTerms(postID uint, weights map[string]int) error {
+	return nil
+}
+
+func (fakeSearch) DeleteTerms(postID uint) error {
+	return nil
+}
+
+type fakeChanges struct {
+	data.ChangeRepo
+}
+
+func (fakeChanges) Touch(name string, at time.Time) error {
+	return nil
+}
+
+// 作者ID为1的已发布文章
+const ownerID = 1
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	cfg.CFG = &cfg.Config{}
+	os.Exit(m.Run())
+}
+
+func setup(t *testing.T) *fakePosts {
+	t.Helper()
+	posts := &fakePosts{posts: map[uint]*data.Post{
+		1: {
+			Model: gorm.Model{ID: 1}, Title: "title", Content: "content", UserID: ownerID,
+			Format: render.Markdown, HTMLVersion: render.Version, Status: data.PostPublished,
+		},
+	}}
+	s := &data.Store{Posts: posts, Revisions: fakeRevisions{}, Search: fakeSearch{}, Roles: authtest.Roles{Permissions: []string{"post:update:own", "post:delete:own"}}, Changes: fakeChanges{}}
+	Init(s)
+	auth.Init(s)
+	search.Init(s)
+	feed.Init(s)
+	return posts
+}
+
+func TestUpdatePostPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint
+		status int
+		code   string
+	}{
+		{"unauthenticated", 0, http.StatusUnauthorized, "unauthorized"},
+		{"non-owner", 2, http.StatusForbidden, "not_owner"},
+		{"owner", ownerID, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := setup(t)
+			status, resp := authtest.Request(UpdatePost, http.MethodPut, tt.userID, `{"id":1,"title":"changed"}`)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if tt.code != "" {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Fatalf("error = %+v, want code %q", resp.Error, tt.code)
+				}
+				if posts.posts[1].Title != "title" {
+					t.Fatalf("post was updated by a caller without permission")
+				}
+				return
+			}
+			if !resp.Success || posts.posts[1].Title != "changed" {
+				t.Fatalf("owner update failed: %+v, title %q", resp, posts.posts[1].Title)
+			}
+		})
+	}
+}
+
+func TestDeletePostPermissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint
+		status int
+		code   string
+	}{
+		{"unauthenticated", 0, http.StatusUnauthorized, "unauthorized"},
+		{"non-owner", 2, http.StatusForbidden, "not_owner"},
+		{"owner", ownerID, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := setup(t)
+			status, resp := authtest.Request(DeletePost, http.MethodDelete, tt.userID, `{"id":1}`)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			_, err := posts.FindByID(1)
+			if tt.code != "" {
+				if resp.Error == nil || resp.Error.Code != tt.code {
+					t.Fatalf("error = %+v, want code %q", resp.Error, tt.code)
+				}
+				if err != nil {
+					t.Fatalf("post was deleted by a caller without permission")
+				}
+				return
+			}
+			if !resp.Success || !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Fatalf("owner delete failed: %+v", resp)
+			}
+		})
+	}
+}
+
+// 请求体中的user_id被忽略，作者取自认证身份
+func TestCreatePostIgnoresUserID(t *testing.T) {
+	posts := setup(t)
+	status, resp := authtest.Request(CreatePost, http.MethodPost, ownerID, `{"title":"new","content":"content","user_id":2}`)
+	if status != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %+v", status, http.StatusCreated, resp.Error)
+	}
+	post, ok := posts.posts[2]
+	if !ok {
+		t.Fatalf("post was not created")
+	}
+	if post.UserID != ownerID {
+		t.Fatalf("author = %d, want %d", post.UserID, ownerID)
+	}
+}
@@ -0,0 +1,52 @@
+package post
+
+import (
+	"blog/data"
+	"blog/render"
+
+	"go.uber.org/zap"
+)
+
+// 确定文章的内容格式并渲染HTML缓存：新建文章未指定格式时为Markdown，更新时未指定则保持原格式；
+// 格式和内容都没有变化且缓存有效时不重新渲染
+func applyFormat(input *postInput, post *data.Post, content string) error {
+	if !render.Valid(input.Format) {
+		return render.ErrUnknownFormat
+	}
+	format := input.Format
+	if format == "" {
+		format = post.Format
+	}
+	if format == "" {
+		format = render.Markdown
+	}
+	if format == post.Format && content == post.Content && post.HTMLVersion == render.Version {
+		return nil
+	}
+
+	html, err := render.HTML(format, content)
+	if err != nil {
+		return err
+	}
+	post.Format = format
+	post.HTML = html
+	post.HTMLVersion = render.Version
+	return nil
+}
+
+// 读取文章时检查HTML缓存，渲染规则升级过或文章来自旧版本时重新渲染并保存
+func ensureRendered(post *data.Post) {
+	if post.HTMLVersion == render.Version {
+		return
+	}
+	html, err := render.HTML(post.Format, post.Content)
+	if err != nil {
+		zap.L().Warn("failed to render post", zap.Uint("post_id", post.ID), zap.Error(err))
+		return
+	}
+	post.HTML = html
+	post.HTMLVersion = render.Version
+	if err := store.Posts.SaveHTML(post); err != nil {
+		zap.L().Warn("failed to save rendered post", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+}
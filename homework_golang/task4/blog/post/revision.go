@@ -0,0 +1,213 @@
+package post
+
+import (
+	"blog/auth"
+	"blog/cache"
+	"blog/data"
+	"blog/diff"
+	"blog/feed"
+	"blog/logMnt"
+	"blog/search"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 统一差异中每处修改前后保留的相同行数
+const diffContext = 3
+
+// 以文章当前的标题和内容生成修订
+func newRevision(post *data.Post, userID uint) *data.PostRevision {
+	return &data.PostRevision{
+		PostID:  post.ID,
+		Title:   post.Title,
+		Content: post.Content,
+		UserID:  userID,
+	}
+}
+
+// 查找文章并检查当前用户能否修改，失败时写入错误响应并返回false
+func findEditablePost(c *gin.Context, postID uint) (*data.Post, bool) {
+	if postID == 0 {
+		c.Error(logMnt.InvalidField("post_id", "required", ""))
+		return nil, false
+	}
+	post, err := store.Posts.FindByID(postID)
+	if err != nil {
+		c.Error(logMnt.ErrPostNotFound.Wrap(err))
+		return nil, false
+	}
+	//修订历史只对能修改文章的用户开放
+	if !authorize(c, post.UserID, "post:update") {
+		return nil, false
+	}
+	return post, true
+}
+
+// 查找文章的某个修订，不存在时写入404响应并返回false
+func findRevision(c *gin.Context, postID uint, number int) (*data.PostRevision, bool) {
+	revision, err := store.Revisions.FindByNumber(postID, number)
+	if err != nil {
+		c.Error(logMnt.ErrRevisionNotFound.With("number", number).Wrap(err))
+		return nil, false
+	}
+	return revision, true
+}
+
+// 读取文章的修订列表，从新到旧排列
+func GetRevisions(c *gin.Context) {
+	postID, err := strconv.ParseUint(c.Query("post_id"), 10, 64)
+	if err != nil {
+		c.Error(logMnt.InvalidField("post_id", "invalid", ""))
+		return
+	}
+	post, ok := findEditablePost(c, uint(postID))
+	if !ok {
+		return
+	}
+
+	revisions, err := store.Revisions.List(post.ID)
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get revisions: %w", err)))
+		return
+	}
+
+	zap.L().Info("get post revisions",
+		zap.Uint("post_id", post.ID),
+		zap.Int("count", len(revisions)),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"count": len(revisions),
+		"items": revisions,
+	})
+}
+
+// 比较文章的两个修订：from为旧修订，to为新修订；内容以统一差异格式返回
+func GetRevisionDiff(c *gin.Context) {
+	var query struct {
+		PostID uint `form:"post_id"`
+		From   int  `form:"from" binding:"required,min=1"`
+		To     int  `form:"to" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+	post, ok := findEditablePost(c, query.PostID)
+	if !ok {
+		return
+	}
+	from, ok := findRevision(c, post.ID, query.From)
+	if !ok {
+		return
+	}
+	to, ok := findRevision(c, post.ID, query.To)
+	if !ok {
+		return
+	}
+
+	//逐行比较内容，统计增删的行数；行数过多时比较耗时太长
+	fromLines, toLines := diff.Split(from.Content), diff.Split(to.Content)
+	if len(fromLines) > diff.MaxLines || len(toLines) > diff.MaxLines {
+		c.Error(logMnt.ErrDiffTooLarge.With("max_lines", diff.MaxLines))
+		return
+	}
+	lines := diff.Lines(fromLines, toLines)
+	added, removed := 0, 0
+	for _, line := range lines {
+		switch line.Op {
+		case diff.Insert:
+			added++
+		case diff.Delete:
+			removed++
+		}
+	}
+	result := gin.H{
+		"from":    from.Number,
+		"to":      to.Number,
+		"added":   added,
+		"removed": removed,
+		"content": diff.Unified(fmt.Sprintf("revision %d", from.Number), fmt.Sprintf("revision %d", to.Number), lines, diffContext),
+	}
+	if from.Title != to.Title {
+		result["title"] = gin.H{"from": from.Title, "to": to.Title}
+	}
+
+	zap.L().Info("get post revision diff",
+		zap.Uint("post_id", post.ID),
+		zap.Int("from", from.Number),
+		zap.Int("to", to.Number),
+	)
+	logMnt.Success(c, http.StatusOK, result)
+}
+
+// 把文章恢复为某个修订的标题和内容，恢复本身作为一个新修订保存
+func RestoreRevision(c *gin.Context) {
+	var input struct {
+		PostID uint `json:"post_id"`
+		Number int  `json:"number" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+	post, ok := findEditablePost(c, input.PostID)
+	if !ok {
+		return
+	}
+	revision, ok := findRevision(c, post.ID, input.Number)
+	if !ok {
+		return
+	}
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	//按文章当前的格式重新渲染恢复后的内容
+	changes := data.Post{Content: post.Content, Format: post.Format, HTMLVersion: post.HTMLVersion}
+	if err := applyFormat(&postInput{}, &changes, revision.Content); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("restore revision: %w", err)))
+		return
+	}
+	changes.Title, changes.Content = revision.Title, revision.Content
+
+	var restored *data.PostRevision
+	err = store.Transaction(func(tx *data.Store) error {
+		if err := tx.Posts.Update(post, &changes); err != nil {
+			return err
+		}
+		restored = newRevision(post, userID)
+		restored.RestoredFrom = &revision.Number
+		return tx.Revisions.Create(restored)
+	})
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("restore revision: %w", err)))
+		return
+	}
+
+	//更新搜索索引
+	if err := search.IndexPost(post); err != nil {
+		zap.L().Warn("failed to index post", zap.Uint("post_id", post.ID), zap.Error(err))
+	}
+	cache.InvalidatePosts(post.ID)
+	feed.Touch()
+
+	zap.L().Info("restore post revision",
+		zap.Uint("post_id", post.ID),
+		zap.Int("restored_from", revision.Number),
+		zap.Int("number", restored.Number),
+		zap.Uint("user_id", userID),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"id":            post.ID,
+		"title":         post.Title,
+		"content":       post.Content,
+		"number":        restored.Number,
+		"restored_from": revision.Number,
+	})
+}
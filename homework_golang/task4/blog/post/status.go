@@ -0,0 +1,78 @@
+package post
+
+import (
+	"blog/cache"
+	"blog/data"
+	"blog/feed"
+	"blog/search"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidStatus    = errors.New("status must be draft, scheduled, published or archived")
+	ErrInvalidPublishAt = errors.New("scheduled posts need a future publish_at and published posts cannot have one")
+)
+
+// 根据请求中的status和publish_at确定文章的状态和发布时间：
+// 新建文章未指定状态时立即发布，指定了未来的publish_at时定时发布；更新时未指定状态则保持原状态
+func applyStatus(input *postInput, post *data.Post) error {
+	now := time.Now()
+	status := input.Status
+	if status == "" {
+		status = post.Status
+	}
+	if status == "" {
+		status = data.PostPublished
+		if input.PublishAt != nil && input.PublishAt.After(now) {
+			status = data.PostScheduled
+		}
+	}
+
+	publishAt := post.PublishAt
+	if input.PublishAt != nil {
+		publishAt = input.PublishAt
+	}
+
+	switch status {
+	case data.PostDraft, data.PostArchived:
+	case data.PostScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return ErrInvalidPublishAt
+		}
+	case data.PostPublished:
+		//可以指定过去的发布时间，未指定时记录本次发布的时间
+		if input.PublishAt != nil && input.PublishAt.After(now) {
+			return ErrInvalidPublishAt
+		}
+		if input.PublishAt == nil && (post.Status != data.PostPublished || publishAt == nil) {
+			publishAt = &now
+		}
+	default:
+		return ErrInvalidStatus
+	}
+
+	post.Status = status
+	post.PublishAt = publishAt
+	return nil
+}
+
+// 发布到期的定时文章、建立搜索索引并清除缓存，返回本次发布的文章数
+func PublishDue() (int, error) {
+	posts, err := store.Posts.PublishDue(time.Now())
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		zap.L().Info("publish scheduled post", zap.Uint("post_id", posts[i].ID), zap.String("title", posts[i].Title))
+		if err := search.IndexPost(&posts[i]); err != nil {
+			zap.L().Warn("failed to index post", zap.Uint("post_id", posts[i].ID), zap.Error(err))
+		}
+		ids[i] = posts[i].ID
+	}
+	if len(ids) > 0 {
+		cache.InvalidatePosts(ids...)
+		feed.Touch()
+	}
+	return len(posts), err
+}
@@ -0,0 +1,49 @@
+package post
+
+import (
+	"blog/data"
+	"sync"
+)
+
+// 尚未写入数据库的文章浏览数，定期由FlushViews批量累加，避免热门文章每次浏览都争抢行锁
+var views = struct {
+	sync.Mutex
+	pending map[uint]int64
+}{pending: map[uint]int64{}}
+
+// 记录一次浏览
+func recordView(postID uint) {
+	views.Lock()
+	views.pending[postID]++
+	views.Unlock()
+}
+
+// 在数据库中的浏览数上加上尚未写入的部分
+func fillViews(posts []data.Post) {
+	views.Lock()
+	defer views.Unlock()
+	for i := range posts {
+		posts[i].ViewCount += views.pending[posts[i].ID]
+	}
+}
+
+// 将缓冲的浏览数写入数据库，返回写入的文章数；写入失败时把计数放回缓冲区，下次再写
+func FlushViews() (int, error) {
+	views.Lock()
+	batch := views.pending
+	views.pending = make(map[uint]int64, len(batch))
+	views.Unlock()
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	if err := store.Posts.AddViews(batch); err != nil {
+		views.Lock()
+		for id, n := range batch {
+			views.pending[id] += n
+		}
+		views.Unlock()
+		return 0, err
+	}
+	return len(batch), nil
+}
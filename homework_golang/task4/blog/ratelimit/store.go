@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 令牌桶存储，默认保存在进程内存中，多实例部署时可替换为共享存储
+type Store interface {
+	// 从key对应的令牌桶中取出一个令牌；桶为空时返回需要等待的时间
+	Take(key string, rate float64, burst int) (ok bool, remaining int, retryAfter time.Duration, err error)
+}
+
+// 闲置的令牌桶每隔sweepInterval清理一次
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time //令牌补满的时间，之后可以丢弃该桶
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// 创建进程内的令牌桶存储
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *memoryStore) Take(key string, rate float64, burst int) (bool, int, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+
+	//按经过的时间补充令牌，不超过桶容量
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, 0, wait, nil
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	return true, int(b.tokens), 0, nil
+}
+
+// 丢弃已经补满的令牌桶，它们与新建的桶没有区别
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
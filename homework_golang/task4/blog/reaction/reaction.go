@@ -0,0 +1,212 @@
+package reaction
+
+import (
+	"blog/auth"
+	"blog/cache"
+	"blog/data"
+	"blog/logMnt"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 支持的表态
+var Kinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+var store *data.Store
+
+// 注入表态模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 表态请求，id为文章或评论的ID
+type reactionInput struct {
+	ID   uint   `json:"id" binding:"required"`
+	Kind string `json:"kind" binding:"required"`
+}
+
+// 对文章表态
+func AddPostReaction(c *gin.Context) {
+	react(c, data.ReactionPost, true)
+}
+
+// 取消对文章的表态
+func RemovePostReaction(c *gin.Context) {
+	react(c, data.ReactionPost, false)
+}
+
+// 对评论表态
+func AddCommentReaction(c *gin.Context) {
+	react(c, data.ReactionComment, true)
+}
+
+// 取消对评论的表态
+func RemoveCommentReaction(c *gin.Context) {
+	react(c, data.ReactionComment, false)
+}
+
+// 添加或取消表态，成功后返回对象最新的表态数量
+func react(c *gin.Context, targetType string, add bool) {
+	var input reactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+	if !slices.Contains(Kinds, input.Kind) {
+		c.Error(logMnt.InvalidField("kind", "oneof", strings.Join(Kinds, " ")))
+		return
+	}
+
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		c.Error(logMnt.ErrUnauthorized.Wrap(err))
+		return
+	}
+
+	//只能对当前用户可见的文章及其评论表态
+	if !findTarget(c, targetType, input.ID) {
+		return
+	}
+
+	reaction := data.Reaction{TargetType: targetType, TargetID: input.ID, UserID: userID, Kind: input.Kind}
+	var changed bool
+	if add {
+		changed, err = store.Reactions.Add(&reaction)
+	} else {
+		changed, err = store.Reactions.Remove(&reaction)
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("save reaction: %w", err)))
+		return
+	}
+	if !add && !changed {
+		c.Error(logMnt.ErrReactionNotFound)
+		return
+	}
+	//文章的表态数量缓存在文章详情和列表中
+	if changed && targetType == data.ReactionPost {
+		cache.InvalidatePosts(input.ID)
+	}
+
+	counts, err := store.Reactions.Counts(targetType, []uint{input.ID})
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("count reactions: %w", err)))
+		return
+	}
+
+	zap.L().Info("react",
+		zap.String("target_type", targetType),
+		zap.Uint("target_id", input.ID),
+		zap.Uint("user_id", userID),
+		zap.String("kind", input.Kind),
+		zap.Bool("add", add),
+		zap.Bool("changed", changed),
+	)
+	status := http.StatusOK
+	if add && changed {
+		//重复表态不报错，返回200而不是201
+		status = http.StatusCreated
+	}
+	logMnt.Success(c, status, gin.H{
+		"target_type": targetType,
+		"id":          input.ID,
+		"kind":        input.Kind,
+		"reactions":   orEmpty(counts[input.ID]),
+	})
+}
+
+// 检查表态的对象存在且对当前用户可见，否则写入404响应并返回false
+func findTarget(c *gin.Context, targetType string, id uint) bool {
+	postID := id
+	if targetType == data.ReactionComment {
+		comment, err := store.Comments.FindByID(id)
+		if err != nil {
+			c.Error(logMnt.ErrCommentNotFound)
+			return false
+		}
+		postID = comment.PostID
+	}
+
+	post, err := store.Posts.FindByID(postID)
+	if err != nil || !post.VisibleTo(auth.ViewerID(c)) {
+		c.Error(logMnt.ErrPostNotFound)
+		return false
+	}
+	return true
+}
+
+// 一批对象的表态数量和当前用户做过的表态，viewerID为0时不查询后者
+func Load(targetType string, ids []uint, viewerID uint) (map[uint]map[string]int64, map[uint][]string, error) {
+	counts, err := store.Reactions.Counts(targetType, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	reacted, err := store.Reactions.ByUser(viewerID, targetType, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return counts, reacted, nil
+}
+
+// 为一批文章填充表态数量和当前用户做过的表态
+func FillPosts(posts []data.Post, viewerID uint) error {
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	counts, reacted, err := Load(data.ReactionPost, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reactions = orEmpty(counts[posts[i].ID])
+		posts[i].Reacted = reacted[posts[i].ID]
+	}
+	return nil
+}
+
+// 为一批评论填充表态数量和当前用户做过的表态
+func FillComments(comments []data.Comment, viewerID uint) error {
+	ids := make([]uint, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	counts, reacted, err := Load(data.ReactionComment, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reactions = orEmpty(counts[comments[i].ID])
+		comments[i].Reacted = reacted[comments[i].ID]
+	}
+	return nil
+}
+
+// 没有表态时返回空的map，使响应中的reactions始终是对象
+func orEmpty(counts map[string]int64) map[string]int64 {
+	if counts == nil {
+		return map[string]int64{}
+	}
+	return counts
+}
+
+// 为一批文章填充当前用户做过的表态，表态数量来自缓存时使用
+func FillReacted(posts []data.Post, viewerID uint) error {
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	reacted, err := store.Reactions.ByUser(viewerID, data.ReactionPost, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reacted = reacted[posts[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// 文章内容格式
+const (
+	Markdown = "markdown"
+	Plain    = "plain"
+)
+
+// 渲染规则的版本，修改渲染或过滤规则后加1，缓存的旧版本HTML会在读取时重新渲染
+const Version = 1
+
+var ErrUnknownFormat = errors.New("format must be markdown or plain")
+
+var (
+	//允许Markdown中的原始HTML，统一由policy过滤
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.Table, extension.Strikethrough, extension.Linkify),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(headingAnchors{}, 100)),
+		),
+		goldmark.WithRendererOptions(gmhtml.WithUnsafe()),
+	)
+
+	policy = newPolicy()
+
+	blankLine = regexp.MustCompile(`\n\s*\n`)
+)
+
+// 在UGC规则的基础上允许代码块的语言class（供前端高亮）和标题锚点
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^anchor$`)).OnElements("a")
+	return p
+}
+
+// 格式是否有效，空字符串表示使用默认格式
+func Valid(format string) bool {
+	return format == "" || format == Markdown || format == Plain
+}
+
+// 把文章内容按格式渲染为经过XSS过滤的HTML
+func HTML(format, content string) (string, error) {
+	switch format {
+	case "", Markdown:
+		var buf bytes.Buffer
+		ctx := parser.NewContext(parser.WithIDs(&headingIDs{used: map[string]bool{}}))
+		if err := markdown.Convert([]byte(content), &buf, parser.WithContext(ctx)); err != nil {
+			return "", err
+		}
+		return policy.Sanitize(buf.String()), nil
+	case Plain:
+		return plain(content), nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// 读取缓存的HTML，缓存由旧版本的渲染规则生成时重新渲染
+func Cached(format, content, html string, version int) (string, error) {
+	if version == Version {
+		return html, nil
+	}
+	return HTML(format, content)
+}
+
+// 纯文本：空行分段，段内换行保留为<br>
+func plain(content string) string {
+	var b strings.Builder
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	for _, paragraph := range blankLine.Split(content, -1) {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+// 标题ID：保留字母（包括中文）和数字，空白和连字符合并为一个连字符，重复的ID加数字后缀
+type headingIDs struct {
+	used map[string]bool
+}
+
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(string(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			dash = true
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "heading"
+	}
+
+	unique := id
+	for i := 1; s.used[unique]; i++ {
+		unique = id + "-" + strconv.Itoa(i)
+	}
+	s.used[unique] = true
+	return []byte(unique)
+}
+
+func (s *headingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+// 在每个有ID的标题末尾加上指向自身的锚点链接
+type headingAnchors struct{}
+
+func (headingAnchors) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		value, ok := id.([]byte)
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		link := ast.NewLink()
+		link.Destination = append([]byte("#"), value...)
+		link.SetAttributeString("class", []byte("anchor"))
+		link.AppendChild(link, ast.NewString([]byte("#")))
+		heading.AppendChild(heading, ast.NewString([]byte(" ")))
+		heading.AppendChild(heading, link)
+		return ast.WalkSkipChildren, nil
+	})
+}
@@ -0,0 +1,249 @@
+package search
+
+import (
+	"blog/data"
+	"blog/logMnt"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 标题中的词项权重高于正文
+const titleWeight = 5
+
+// 搜索结果默认和最大分页大小
+const (
+	defaultPageSize = 10
+	maxPageSize     = 50
+)
+
+// 摘要长度（按字符计）
+const snippetLength = 120
+
+var store *data.Store
+
+// 注入搜索模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 一条搜索结果
+type Hit struct {
+	Post  data.Post
+	Score float64
+}
+
+// 为文章的标题和正文建立索引，文章更新后重新调用即可覆盖旧索引；未发布的文章移出索引
+func IndexPost(post *data.Post) error {
+	if post.Status != data.PostPublished {
+		return RemovePost(post.ID)
+	}
+	weights := map[string]int{}
+	for _, term := range tokenize(post.Title, true) {
+		weights[term] += titleWeight
+	}
+	for _, term := range tokenize(post.Content, true) {
+		weights[term]++
+	}
+	return store.Search.ReplaceTerms(post.ID, weights)
+}
+
+// 删除文章的索引
+func RemovePost(postID uint) error {
+	return store.Search.DeleteTerms(postID)
+}
+
+// 重建全部文章的索引，返回处理的文章数
+func Reindex() (int, error) {
+	count := 0
+	query := data.ListQuery{Limit: data.MaxPageSize}
+	for {
+		page, err := store.Posts.List(query)
+		if err != nil {
+			return count, err
+		}
+		for i := range page.Items {
+			if err := IndexPost(&page.Items[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if page.NextCursor == "" {
+			return count, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// 查询包含全部词项的文章，按相关度从高到低返回第offset条开始的limit条，以及命中总数；排序和分页在数据库中完成
+func Query(q string, offset, limit int) ([]Hit, int, error) {
+	terms := uniqueTerms(Tokenize(q))
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+
+	n, err := store.Search.DocumentCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	df, err := store.Search.DocumentFrequency(terms)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	//任一词项没有出现在任何文章中时没有结果
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		if df[term] == 0 {
+			return nil, 0, nil
+		}
+		idf[term] = math.Log(1 + float64(n)/float64(df[term]))
+	}
+
+	hits, total, err := store.Search.Match(idf, offset, limit)
+	if err != nil || len(hits) == 0 {
+		return nil, int(total), err
+	}
+
+	//读取本页文章内容
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.PostID
+	}
+	posts, err := store.Posts.FindByIDs(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]data.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+
+	page := make([]Hit, 0, len(hits))
+	for _, hit := range hits {
+		if post, ok := byID[hit.PostID]; ok && post.Status == data.PostPublished {
+			page = append(page, Hit{Post: post, Score: hit.Score})
+		}
+	}
+	return page, int(total), nil
+}
+
+// 搜索文章：q为关键词，page从1开始，size为每页条数
+func Search(c *gin.Context) {
+	//获取搜索参数
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.Error(logMnt.InvalidField("q", "required", ""))
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.Error(logMnt.InvalidField("page", "min", "1"))
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultPageSize)))
+	if err != nil || size < 1 {
+		c.Error(logMnt.InvalidField("size", "min", "1"))
+		return
+	}
+	size = min(size, maxPageSize)
+
+	//查询索引
+	hits, total, err := Query(q, (page-1)*size, size)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("search posts: %w", err)))
+		return
+	}
+
+	//生成高亮标题和摘要
+	terms := uniqueTerms(Tokenize(q))
+	results := make([]gin.H, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, gin.H{
+			"id":         hit.Post.ID,
+			"title":      highlight(hit.Post.Title, terms, 0),
+			"snippet":    highlight(hit.Post.Content, terms, snippetLength),
+			"score":      hit.Score,
+			"user_id":    hit.Post.UserID,
+			"created_at": hit.Post.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	zap.L().Info("search posts",
+		zap.String("query", q),
+		zap.Int("total", total),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"query": q,
+		"page":  page,
+		"size":  size,
+		"total": total,
+		"items": results,
+	})
+}
+
+// 用<mark>标记文本中出现的词项，其余内容做HTML转义；length大于0时截取第一个命中位置附近的片段
+func highlight(text string, terms []string, length int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	//标记命中的字符
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	//截取片段，命中位置前保留少量上下文
+	start, end := 0, len(runes)
+	if length > 0 && len(runes) > length {
+		if first > 0 {
+			start = max(0, first-length/4)
+		}
+		end = min(len(runes), start+length)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<mark>" + segment + "</mark>")
+		} else {
+			b.WriteString(segment)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"unicode"
+)
+
+// 单个词项的最大长度（按字符计）
+const maxTermLength = 64
+
+// 是否为中日韩字符，这类文本没有空格分词，按相邻两字切分
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// 切分查询词：拉丁字母和数字按单词切分并转为小写，中日韩文本按二元组切分，单独的一个字作为一个词项
+func Tokenize(text string) []string {
+	return tokenize(text, false)
+}
+
+// 分词，unigrams为true时中日韩文本在二元组之外再输出每个单字，用于建立索引，使单字查询也能命中
+func tokenize(text string, unigrams bool) []string {
+	var tokens []string
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 && len(word) <= maxTermLength {
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 || unigrams {
+			for _, r := range cjk {
+				tokens = append(tokens, string(r))
+			}
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			tokens = append(tokens, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return tokens
+}
+
+// 去重后的词项，保持首次出现的顺序
+func uniqueTerms(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if !seen[token] {
+			seen[token] = true
+			terms = append(terms, token)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,197 @@
+package taxonomy
+
+import (
+	"blog/cache"
+	"blog/data"
+	"blog/logMnt"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 分类树中的节点，post_count为直接属于该分类的文章数
+type categoryNode struct {
+	data.Category
+	PostCount int64           `json:"post_count"`
+	Children  []*categoryNode `json:"children"`
+}
+
+// 读取分类树
+func GetCategories(c *gin.Context) {
+	categories, err := store.Categories.List()
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get categories: %w", err)))
+		return
+	}
+	counts, err := store.Categories.PostCounts()
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get categories: %w", err)))
+		return
+	}
+
+	//先建立全部节点，再挂到各自的上级下面
+	nodes := make(map[uint]*categoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &categoryNode{Category: category, PostCount: counts[category.ID], Children: []*categoryNode{}}
+	}
+	roots := []*categoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if parent, ok := nodes[derefID(category.ParentID)]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"count": len(categories),
+		"items": roots,
+	})
+}
+
+// 创建分类，parent_id为空时创建顶级分类，需要category:manage权限
+func CreateCategory(c *gin.Context) {
+	var req struct {
+		Name     string `json:"name" binding:"required"`
+		ParentID *uint  `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	name, slug, err := normalize(req.Name)
+	if err != nil {
+		c.Error(logMnt.InvalidField("name", "invalid", "").Wrap(err))
+		return
+	}
+	if !checkSlug(c, checkCategorySlug(slug, 0), "create category") {
+		return
+	}
+
+	category := data.Category{Name: name, Slug: slug}
+	if derefID(req.ParentID) != 0 {
+		if _, err := store.Categories.FindByID(*req.ParentID); err != nil {
+			c.Error(logMnt.InvalidField("parent_id", "exists", ""))
+			return
+		}
+		category.ParentID = req.ParentID
+	}
+
+	if err := store.Categories.Create(&category); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("create category: %w", err)))
+		return
+	}
+
+	zap.L().Info("create category", zap.Uint("category_id", category.ID), zap.String("name", category.Name))
+	logMnt.Success(c, http.StatusCreated, category)
+}
+
+// 修改分类名称或上级分类（parent_id为0时移到顶级），需要category:manage权限
+func UpdateCategory(c *gin.Context) {
+	var req struct {
+		ID       uint   `json:"id" binding:"required"`
+		Name     string `json:"name"`
+		ParentID *uint  `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	category, err := store.Categories.FindByID(req.ID)
+	if err != nil {
+		c.Error(logMnt.ErrCategoryNotFound)
+		return
+	}
+
+	changes := map[string]interface{}{}
+	if req.Name != "" {
+		name, slug, err := normalize(req.Name)
+		if err != nil {
+			c.Error(logMnt.InvalidField("name", "invalid", "").Wrap(err))
+			return
+		}
+		if !checkSlug(c, checkCategorySlug(slug, category.ID), "update category") {
+			return
+		}
+		changes["name"] = name
+		changes["slug"] = slug
+	}
+
+	if req.ParentID != nil {
+		if *req.ParentID == 0 {
+			changes["parent_id"] = nil
+		} else {
+			//不能移到自身或子孙分类下面
+			descendants, err := store.Categories.Descendants(category.ID)
+			if err != nil {
+				c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update category: %w", err)))
+				return
+			}
+			if slices.Contains(descendants, *req.ParentID) {
+				c.Error(logMnt.ErrCategoryCycle.Wrap(ErrCategoryCycle))
+				return
+			}
+			if _, err := store.Categories.FindByID(*req.ParentID); err != nil {
+				c.Error(logMnt.InvalidField("parent_id", "exists", ""))
+				return
+			}
+			changes["parent_id"] = *req.ParentID
+		}
+	}
+
+	if len(changes) > 0 {
+		if err := store.Categories.Update(category, changes); err != nil {
+			c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update category: %w", err)))
+			return
+		}
+	}
+
+	//文章详情和列表中带有标签和分类
+	cache.InvalidateAllPosts()
+
+	zap.L().Info("update category", zap.Uint("category_id", category.ID), zap.String("name", category.Name))
+	logMnt.Success(c, http.StatusOK, category)
+}
+
+// 删除分类，子分类移到其上级分类下，需要category:manage权限
+func DeleteCategory(c *gin.Context) {
+	var req struct {
+		ID uint `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	category, err := store.Categories.FindByID(req.ID)
+	if err != nil {
+		c.Error(logMnt.ErrCategoryNotFound)
+		return
+	}
+
+	if err := store.Categories.Delete(category); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("delete category: %w", err)))
+		return
+	}
+
+	//文章详情和列表中带有标签和分类
+	cache.InvalidateAllPosts()
+
+	zap.L().Info("delete category", zap.Uint("category_id", category.ID), zap.String("name", category.Name))
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"id": category.ID,
+	})
+}
+
+func derefID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
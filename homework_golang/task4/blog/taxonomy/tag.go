@@ -0,0 +1,152 @@
+package taxonomy
+
+import (
+	"blog/cache"
+	"blog/data"
+	"blog/feed"
+	"blog/logMnt"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 标签云：每个标签及其文章数，按文章数从多到少排列；limit为空时返回全部标签
+func GetTags(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.Error(logMnt.InvalidField("limit", "min", "1"))
+			return
+		}
+		limit = n
+	}
+
+	tags, err := store.Tags.Counts(limit)
+	if err != nil {
+		c.Error(logMnt.ErrDatabaseConnection.Wrap(fmt.Errorf("get tags: %w", err)))
+		return
+	}
+
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"count": len(tags),
+		"items": tags,
+	})
+}
+
+// 创建标签，需要tag:manage权限
+func CreateTag(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	name, slug, err := normalize(req.Name)
+	if err != nil {
+		c.Error(logMnt.InvalidField("name", "invalid", "").Wrap(err))
+		return
+	}
+	if !checkSlug(c, checkTagSlug(slug, 0), "create tag") {
+		return
+	}
+
+	tag := data.Tag{Name: name, Slug: slug}
+	if err := store.Tags.Create(&tag); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("create tag: %w", err)))
+		return
+	}
+
+	zap.L().Info("create tag", zap.Uint("tag_id", tag.ID), zap.String("name", tag.Name))
+	logMnt.Success(c, http.StatusCreated, tag)
+}
+
+// 重命名标签，需要tag:manage权限
+func UpdateTag(c *gin.Context) {
+	var req struct {
+		ID   uint   `json:"id" binding:"required"`
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	tag, err := store.Tags.FindByID(req.ID)
+	if err != nil {
+		c.Error(logMnt.ErrTagNotFound)
+		return
+	}
+
+	name, slug, err := normalize(req.Name)
+	if err != nil {
+		c.Error(logMnt.InvalidField("name", "invalid", "").Wrap(err))
+		return
+	}
+	//新名称不能与其他标签重复
+	if !checkSlug(c, checkTagSlug(slug, tag.ID), "update tag") {
+		return
+	}
+
+	if err := store.Tags.Update(tag, name, slug); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update tag: %w", err)))
+		return
+	}
+
+	//文章详情和列表中带有标签和分类
+	cache.InvalidateAllPosts()
+	feed.Touch()
+
+	zap.L().Info("update tag", zap.Uint("tag_id", tag.ID), zap.String("name", tag.Name))
+	logMnt.Success(c, http.StatusOK, tag)
+}
+
+// 删除标签，文章上的该标签一并移除，需要tag:manage权限
+func DeleteTag(c *gin.Context) {
+	var req struct {
+		ID uint `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	tag, err := store.Tags.FindByID(req.ID)
+	if err != nil {
+		c.Error(logMnt.ErrTagNotFound)
+		return
+	}
+
+	if err := store.Tags.Delete(tag); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("delete tag: %w", err)))
+		return
+	}
+
+	//文章详情和列表中带有标签和分类
+	cache.InvalidateAllPosts()
+	feed.Touch()
+
+	zap.L().Info("delete tag", zap.Uint("tag_id", tag.ID), zap.String("name", tag.Name))
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"id": tag.ID,
+	})
+}
+
+// 处理名称重复检查的结果，重复时返回409，不能继续时写入错误响应并返回false；action用于错误日志
+func checkSlug(c *gin.Context, err error, action string) bool {
+	if errors.Is(err, ErrDuplicateSlug) {
+		c.Error(logMnt.ErrNameConflict.Wrap(err))
+		return false
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("%s: %w", action, err)))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package taxonomy
+
+import (
+	"blog/data"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 标签和分类名称的最大长度（按字节，与数据库字段长度一致），以及一篇文章最多的标签数
+const (
+	maxNameLength = 64
+	MaxPostTags   = 10
+)
+
+var (
+	ErrInvalidName      = errors.New("name must contain letters or digits and be at most 64 bytes")
+	ErrTooManyTags      = fmt.Errorf("a post can have at most %d tags", MaxPostTags)
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryCycle    = errors.New("category cannot be moved under itself or its subcategory")
+	ErrDuplicateSlug    = errors.New("name is already in use")
+)
+
+var store *data.Store
+
+// 注入标签和分类模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
+// 由名称生成Slug：转为小写，字母和数字以外的字符合并为一个"-"；中文等非拉丁字母保留
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// 整理名称并生成Slug
+func normalize(name string) (string, string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	slug := Slugify(name)
+	if slug == "" || len(name) > maxNameLength || !utf8.ValidString(name) {
+		return "", "", ErrInvalidName
+	}
+	return name, slug, nil
+}
+
+// Slug已被其他标签使用时返回ErrDuplicateSlug，id为当前标签（新建时为0）
+func checkTagSlug(slug string, id uint) error {
+	existing, err := store.Tags.FindBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != id {
+		return ErrDuplicateSlug
+	}
+	return nil
+}
+
+// Slug已被其他分类使用时返回ErrDuplicateSlug，id为当前分类（新建时为0）
+func checkCategorySlug(slug string, id uint) error {
+	existing, err := store.Categories.FindBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != id {
+		return ErrDuplicateSlug
+	}
+	return nil
+}
+
+// 把文章的标签名解析为标签，名称相同（Slug相同）的只保留一个；只做校验，不写数据表，
+// 不存在的标签由保存文章的事务通过SaveTags创建
+func ParseTags(names []string) ([]data.Tag, error) {
+	tags := make([]data.Tag, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		normalized, slug, err := normalize(name)
+		if err != nil {
+			return nil, fmt.Errorf("tag %q: %w", name, err)
+		}
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, data.Tag{Name: normalized, Slug: slug})
+	}
+	if len(tags) > MaxPostTags {
+		return nil, ErrTooManyTags
+	}
+	return tags, nil
+}
+
+// 在事务tx中查找或创建ParseTags解析出的标签，返回带ID的标签
+func SaveTags(tx *data.Store, tags []data.Tag) ([]data.Tag, error) {
+	if len(tags) == 0 {
+		return tags, nil
+	}
+	return tx.Tags.FindOrCreate(tags)
+}
+
+// 按ID查找文章的分类，任一分类不存在时返回ErrCategoryNotFound
+func ResolveCategories(ids []uint) ([]data.Category, error) {
+	if len(ids) == 0 {
+		return []data.Category{}, nil
+	}
+	categories, err := store.Categories.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := map[uint]bool{}
+	for _, category := range categories {
+		found[category.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: %d", ErrCategoryNotFound, id)
+		}
+	}
+	return categories, nil
+}
@@ -0,0 +1,268 @@
+package token
+
+import (
+	"blog/cfg"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+)
+
+// 一个签名密钥，signKey为空时只用于验证
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	retiredAt *time.Time
+}
+
+// 密钥是否仍可用于验证：未退役，或仍在退役宽限期内
+func (k *signingKey) usable(now time.Time) bool {
+	return k.retiredAt == nil || now.Before(k.retiredAt.Add(retireGrace()))
+}
+
+// 当前生效的密钥集合
+var keys struct {
+	active *signingKey
+	byKid  map[string]*signingKey
+	legacy *signingKey //验证不带kid的令牌
+	//配置keys后接受不带kid的旧令牌的截止时间，为空时不再接受
+	legacyUntil *time.Time
+}
+
+func retireGrace() time.Duration {
+	if cfg.CFG.Jwt.RetireGrace > 0 {
+		return cfg.CFG.Jwt.RetireGrace
+	}
+	return accessTTL()
+}
+
+// 按配置加载签名密钥
+func loadKeys(c cfg.JwtConfig) error {
+	keys.active = nil
+	keys.byKid = map[string]*signingKey{}
+	keys.legacy = nil
+	keys.legacyUntil = c.LegacyUntil
+
+	if c.Secret != "" {
+		keys.legacy = &signingKey{
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(c.Secret),
+			verifyKey: []byte(c.Secret),
+		}
+	}
+
+	//未配置密钥集合时沿用单一HS256密钥
+	if len(c.Keys) == 0 {
+		if keys.legacy == nil {
+			return errors.New("jwt secret or keys must be configured")
+		}
+		keys.active = keys.legacy
+		return nil
+	}
+
+	for _, kc := range c.Keys {
+		if kc.Kid == "" {
+			return errors.New("jwt key kid is required")
+		}
+		if _, ok := keys.byKid[kc.Kid]; ok {
+			return fmt.Errorf("duplicate jwt key kid %q", kc.Kid)
+		}
+		key, err := loadKey(kc)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %w", kc.Kid, err)
+		}
+		keys.byKid[kc.Kid] = key
+	}
+
+	active, ok := keys.byKid[c.ActiveKid]
+	if !ok {
+		return fmt.Errorf("active jwt key %q is not configured", c.ActiveKid)
+	}
+	if active.signKey == nil {
+		return fmt.Errorf("active jwt key %q has no private key", c.ActiveKid)
+	}
+	if active.retiredAt != nil {
+		return fmt.Errorf("active jwt key %q is retired", c.ActiveKid)
+	}
+	keys.active = active
+	return nil
+}
+
+func loadKey(kc cfg.JwtKeyConfig) (*signingKey, error) {
+	key := &signingKey{kid: kc.Kid, retiredAt: kc.RetiredAt}
+
+	switch kc.Alg {
+	case "HS256":
+		if kc.Secret == "" {
+			return nil, errors.New("secret is required for HS256")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(kc.Secret)
+		key.verifyKey = []byte(kc.Secret)
+		return key, nil
+
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+	case "EdDSA":
+		key.method = SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", kc.Alg)
+	}
+
+	//非对称密钥：有私钥时从私钥推出公钥，否则读取公钥
+	if kc.PrivateKeyFile != "" {
+		private, err := readPrivateKey(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey = private
+		key.verifyKey = private.Public()
+	} else if kc.PublicKeyFile != "" {
+		public, err := readPublicKey(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.verifyKey = public
+	} else {
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+
+	//密钥类型必须与算法一致
+	switch key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		if kc.Alg != "RS256" {
+			return nil, fmt.Errorf("RSA key cannot be used with %s", kc.Alg)
+		}
+	case ed25519.PublicKey:
+		if kc.Alg != "EdDSA" {
+			return nil, fmt.Errorf("Ed25519 key cannot be used with %s", kc.Alg)
+		}
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	return block, nil
+}
+
+// 读取PKCS#8私钥（RSA也可以是PKCS#1）
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key", path)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// 用当前生效的密钥签名，配置了kid时写入令牌头部
+func sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	if keys.active.kid != "" {
+		token.Header["kid"] = keys.active.kid
+	}
+	return token.SignedString(keys.active.signKey)
+}
+
+// 令牌是否为配置keys之前用jwt.secret签发、不带kid的旧令牌
+func isLegacy(token *jwt.Token) bool {
+	kid, _ := token.Header["kid"].(string)
+	return kid == "" && keys.legacy != nil && keys.legacy != keys.active
+}
+
+// 按令牌头部的kid选择验证密钥，算法必须与密钥一致；不带kid的旧令牌只在jwt.legacy_until之前接受
+func verifyKey(token *jwt.Token) (interface{}, error) {
+	key := keys.legacy
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		key = keys.byKid[kid]
+	}
+	if key == nil || !key.usable(time.Now()) {
+		return nil, jwt.ErrInvalidKey
+	}
+	if isLegacy(token) && (keys.legacyUntil == nil || !time.Now().Before(*keys.legacyUntil)) {
+		return nil, jwt.ErrInvalidKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.verifyKey, nil
+}
+
+// 公开仍可用于验证的非对称密钥，供其他服务验证令牌
+func JWKS(c *gin.Context) {
+	now := time.Now()
+	jwks := make([]gin.H, 0, len(keys.byKid))
+	for _, key := range keys.byKid {
+		if !key.usable(now) {
+			continue
+		}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, gin.H{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"kid": key.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, gin.H{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"use": "sig",
+				"alg": key.method.Alg(),
+				"kid": key.kid,
+				"x":   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": jwks})
+}
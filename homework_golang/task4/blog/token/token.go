@@ -0,0 +1,230 @@
+package token
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 未配置时的令牌有效期
+const (
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken        = errors.New("invalid token or token has expired")
+	ErrRevoked             = errors.New("token has been revoked")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token or refresh token has expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+var store *data.Store
+
+// 注入令牌模块依赖的仓库并加载签名密钥
+func Init(s *data.Store) error {
+	store = s
+	return loadKeys(cfg.CFG.Jwt)
+}
+
+// 访问令牌中的声明
+type Claims struct {
+	UserID   uint   `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	FamilyID string `json:"fam"` //签发该令牌的登录会话（令牌族）
+	jwt.StandardClaims
+}
+
+// 登录或刷新后返回给客户端的一对令牌
+type Pair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+func accessTTL() time.Duration {
+	if cfg.CFG.Jwt.AccessTTL > 0 {
+		return cfg.CFG.Jwt.AccessTTL
+	}
+	return defaultAccessTTL
+}
+
+func refreshTTL() time.Duration {
+	if cfg.CFG.Jwt.RefreshTTL > 0 {
+		return cfg.CFG.Jwt.RefreshTTL
+	}
+	return defaultRefreshTTL
+}
+
+// 生成随机字符串，用作jti、令牌族ID和刷新令牌
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// 数据库中只保存刷新令牌的哈希
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// 登录成功后开启新的令牌族并签发令牌
+func IssuePair(user *data.User) (*Pair, error) {
+	familyID, err := randomString(24)
+	if err != nil {
+		return nil, err
+	}
+	return issue(user, familyID)
+}
+
+func issue(user *data.User, familyID string) (*Pair, error) {
+	now := time.Now()
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	role := ""
+	if user.Role != nil {
+		role = user.Role.Name
+	}
+	claims := Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     role,
+		FamilyID: familyID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(accessTTL()).Unix(),
+		},
+	}
+	accessToken, err := sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	refresh := data.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(refreshToken),
+		ExpiresAt: now.Add(refreshTTL()),
+	}
+	if err := store.Tokens.CreateRefresh(&refresh); err != nil {
+		return nil, err
+	}
+
+	return &Pair{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  time.Unix(claims.ExpiresAt, 0),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// 解析并验证访问令牌，已吊销的令牌返回ErrRevoked
+func Parse(tokenString string) (*Claims, error) {
+	var claims Claims
+	//按kid选择验证密钥，已过退役宽限期的密钥签发的令牌无效
+	token, err := jwt.ParseWithClaims(tokenString, &claims, verifyKey)
+	if err != nil || !token.Valid || claims.UserID == 0 || claims.Id == "" {
+		return nil, ErrInvalidToken
+	}
+	if isLegacy(token) {
+		zap.L().Warn("accepted legacy token without kid",
+			zap.Uint("user_id", claims.UserID),
+			zap.Time("legacy_until", *keys.legacyUntil),
+		)
+	}
+
+	//检查吊销列表：令牌本身或其所属令牌族被吊销都视为无效
+	revoked, err := store.Tokens.IsRevoked(claims.Id, claims.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRevoked
+	}
+	return &claims, nil
+}
+
+// 用刷新令牌换取新的一对令牌，旧刷新令牌随即失效；
+// 已失效的刷新令牌被再次使用时视为泄露，吊销整个令牌族
+func Refresh(raw string) (*Pair, error) {
+	refresh, err := store.Tokens.FindRefreshByHash(hashRefreshToken(raw))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if refresh.UsedAt != nil {
+		if err := revokeFamily(refresh.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if refresh.RevokedAt != nil || time.Now().After(refresh.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	//并发使用同一刷新令牌时只有一个请求能完成轮换，其余按重用处理
+	ok, err := store.Tokens.MarkRefreshUsed(refresh)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := revokeFamily(refresh.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	user, err := store.Users.FindByID(refresh.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return issue(user, refresh.FamilyID)
+}
+
+// 退出登录：吊销当前访问令牌及其令牌族中的所有令牌
+func Logout(claims *Claims) error {
+	if err := store.Tokens.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return err
+	}
+	return revokeFamily(claims.FamilyID)
+}
+
+// 吊销用户的所有登录会话
+func RevokeUser(userID uint) error {
+	return store.Tokens.RevokeUser(userID, time.Now().Add(accessTTL()))
+}
+
+// 族内签发的访问令牌最晚在一个访问令牌有效期后过期，吊销记录保留到那时
+func revokeFamily(familyID string) error {
+	return store.Tokens.RevokeFamily(familyID, time.Now().Add(accessTTL()))
+}
+
+// 清理过期的刷新令牌和吊销记录
+func PurgeExpired() error {
+	return store.Tokens.PurgeExpired(time.Now())
+}
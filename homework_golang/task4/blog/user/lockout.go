@@ -0,0 +1,152 @@
+package user
+
+import (
+	"blog/cfg"
+	"blog/data"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 未配置时的登录失败锁定策略
+const (
+	defaultMaxFailures   = 5
+	defaultIPMaxFailures = 20
+	defaultLockout       = time.Minute
+	defaultMaxLockout    = time.Hour
+	defaultFailureWindow = 24 * time.Hour
+)
+
+// 登录失败的原因
+const (
+	reasonPassword = "password"
+	reasonLocked   = "locked"
+)
+
+// 用户名不存在时用于比较的哈希，使响应时间不暴露用户名是否存在
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// 校验密码，用户不存在时仍然进行一次同样代价的比较
+func checkPassword(user *data.User, password string) bool {
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+func loginPolicy() cfg.LoginConfig {
+	policy := cfg.CFG.Login
+	if policy.MaxFailures <= 0 {
+		policy.MaxFailures = defaultMaxFailures
+	}
+	if policy.IPMaxFailures <= 0 {
+		policy.IPMaxFailures = defaultIPMaxFailures
+	}
+	if policy.Lockout <= 0 {
+		policy.Lockout = defaultLockout
+	}
+	if policy.MaxLockout <= 0 {
+		policy.MaxLockout = defaultMaxLockout
+	}
+	if policy.FailureWindow <= 0 {
+		policy.FailureWindow = defaultFailureWindow
+	}
+	return policy
+}
+
+// 用户存在时按用户ID计数，否则按小写的用户名计数，避免大小写不同的用户名绕过锁定
+func userSubject(user *data.User, username string) string {
+	if user != nil {
+		return "user:" + strconv.FormatUint(uint64(user.ID), 10)
+	}
+	return "username:" + strings.ToLower(username)
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// 用户名或IP处于锁定中时返回剩余的锁定时间
+func lockedFor(subjects ...string) (time.Duration, error) {
+	failures, err := store.Logins.FindFailures(subjects...)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var wait time.Duration
+	for _, failure := range failures {
+		if failure.LockedUntil != nil && failure.LockedUntil.After(now) {
+			wait = max(wait, failure.LockedUntil.Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// 记录一次登录失败，连续失败达到上限时锁定，锁定时长为lockout*2^已锁定次数
+func recordFailure(subject string, maxFailures int) error {
+	policy := loginPolicy()
+	failure, err := store.Logins.RecordFailure(subject, func(failure *data.LoginFailure) {
+		now := time.Now()
+		if !failure.LastFailedAt.IsZero() && now.Sub(failure.LastFailedAt) > policy.FailureWindow {
+			failure.Failures = 0
+			failure.Lockouts = 0
+		}
+		failure.Failures++
+		failure.LastFailedAt = now
+		if failure.Failures >= maxFailures {
+			lockout := policy.Lockout << min(failure.Lockouts, 30)
+			if lockout <= 0 || lockout > policy.MaxLockout {
+				lockout = policy.MaxLockout
+			}
+			lockedUntil := now.Add(lockout)
+			failure.LockedUntil = &lockedUntil
+			failure.Lockouts++
+			failure.Failures = 0
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if failure.Failures == 0 && failure.LockedUntil != nil {
+		zap.L().Warn("login locked after repeated failures",
+			zap.String("subject", subject),
+			zap.Time("locked_until", *failure.LockedUntil),
+			zap.Int("lockouts", failure.Lockouts),
+		)
+	}
+	return nil
+}
+
+// 保存登录记录，失败时只记录日志，不影响登录结果
+func recordAttempt(c *gin.Context, user *data.User, username string, reason string) {
+	attempt := data.LoginAttempt{
+		Username:  username,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   reason == "",
+		Reason:    reason,
+	}
+	if len(attempt.UserAgent) > 255 {
+		attempt.UserAgent = strings.ToValidUTF8(attempt.UserAgent[:255], "")
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	if err := store.Logins.CreateAttempt(&attempt); err != nil {
+		zap.L().Warn("failed to record login attempt", zap.String("username", username), zap.Error(err))
+	}
+}
+
+// 清理过期的登录失败计数
+func PurgeLoginFailures() error {
+	return store.Logins.PurgeFailures(time.Now().Add(-loginPolicy().FailureWindow))
+}
@@ -1,27 +1,42 @@
 package user
 
 import (
-	"blog/cfg"
+	"blog/account"
+	"blog/auth"
 	"blog/data"
+	"blog/i18n"
 	"blog/logMnt"
+	"blog/token"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// 认证中间件存放令牌声明的上下文键
+const claimsKey = "tokenClaims"
+
+var store *data.Store
+
+// 注入用户模块依赖的仓库
+func Init(s *data.Store) {
+	store = s
+}
+
 // 认证中间件：验证用户是否已登录
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		//从请求头获取Authorization字段
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "No authorization header"))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthenticated, please log in first"})
+			c.Error(logMnt.ErrUnauthorized.Wrap(errors.New("no authorization header")))
 			//终止请求处理
 			c.Abort()
 			return
@@ -29,83 +44,92 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 
 		//验证Authorization格式(必须是 "Bearer <token>")
 		if len(authHeader) < 7 || !strings.HasPrefix(authHeader, "Bearer ") {
-			zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "Invalid authorization header"))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format; \"Bearer <token>\""})
-		}
-		tokenString := authHeader[7:]
-
-		//解析并验证token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			//验证签名方法是否为HS256
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(cfg.CFG.Jwt.Secret), nil
-		})
-
-		//检查token有效性
-		if err != nil || !token.Valid {
-			zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "Invalid token or token has expired"))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token or token has expired"})
+			c.Error(logMnt.ErrInvalidToken.Wrap(errors.New(`authorization header must be "Bearer <token>"`)))
 			c.Abort()
 			return
 		}
+		tokenString := authHeader[7:]
 
-		//提取token中的用户ID(需与生成token时的字段一致)
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "Failed to parse token"))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to parse token"})
+		//解析并验证token，同时检查吊销列表
+		claims, err := token.Parse(tokenString)
+		if errors.Is(err, token.ErrInvalidToken) || errors.Is(err, token.ErrRevoked) {
+			c.Error(logMnt.ErrInvalidToken.Wrap(err))
 			c.Abort()
 			return
 		}
-
-		//将用户ID存入上下文，供后续接口使用
-		userID, ok := claims["id"].(float64)
-		if !ok {
-			zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "Invalid user information in token"))
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user information in token"})
+		if err != nil {
+			c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("verify token: %w", err)))
 			c.Abort()
 			return
 		}
-		//存储用户ID(uint类型)
-		c.Set("userID", uint(userID))
+
+		//将用户ID和角色存入上下文，供后续接口使用
+		c.Set(auth.UserIDKey, claims.UserID)
+		c.Set(auth.RoleKey, claims.Role)
+		c.Set(claimsKey, claims)
+		loadLocale(c, claims.UserID)
 
 		//继续处理请求
 		c.Next()
 	}
 }
 
+// 从数据表读取用户的语言偏好，修改后对之后的请求立即生效；读取失败时按Accept-Language处理
+func loadLocale(c *gin.Context, userID uint) {
+	locale, err := store.Users.FindLocale(userID)
+	if err != nil {
+		zap.L().Warn("failed to load user locale", zap.Uint("user_id", userID), zap.Error(err))
+		return
+	}
+	logMnt.SetLocale(c, locale)
+}
+
+// 可选认证中间件：用于公开接口，带有效token时和JWTAuthMiddleware一样写入用户身份，未带或无效时按未登录处理
+func OptionalJWTAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			if claims, err := token.Parse(authHeader[7:]); err == nil {
+				c.Set(auth.UserIDKey, claims.UserID)
+				c.Set(auth.RoleKey, claims.Role)
+				c.Set(claimsKey, claims)
+				loadLocale(c, claims.UserID)
+			}
+		}
+		c.Next()
+	}
+}
+
 func Register(c *gin.Context) {
 	//获取用户注册信息
 	var user data.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid register parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(logMnt.BindError(err))
 		return
 	}
 
 	//加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Failed to hash password"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to hash password"})
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err)))
 		return
 	}
 	user.Password = string(hashedPassword)
 
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//新用户使用默认角色，忽略请求体中的角色
+	role, err := store.Roles.FindByName(data.DefaultRole)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("load default role: %w", err)))
 		return
 	}
+	user.RoleID = role.ID
+	user.Role = nil
+	//邮箱需要通过验证邮件确认，忽略请求体中的验证时间
+	user.EmailVerifiedAt = nil
 
 	//插入用户信息
-	if err := db.Create(&user).Error; err != nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to create user"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+	if err := store.Users.Create(&user); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("create user: %w", err)))
 		return
 	}
 
@@ -114,71 +138,268 @@ func Register(c *gin.Context) {
 		zap.String("username", user.Username),
 		zap.String("password", user.Password),
 	)
+
+	//新用户的邮箱处于未验证状态，发送验证邮件；发送失败时用户可以重新申请
+	registered := user
+	go func() {
+		if err := account.SendVerificationMail(&registered); err != nil {
+			zap.L().Error("failed to send verification email", zap.Uint("user_id", registered.ID), zap.Error(err))
+		}
+	}()
+
 	//返回用户注册成功的信息给客户端
-	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"id":             user.ID,
+		"username":       user.Username,
+		"email_verified": false,
+	})
 }
 
 func Login(c *gin.Context) {
 	//获取用户登录信息
 	var user data.User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		zap.L().Error(logMnt.ErrBadRequest.Message, zap.String("error", "Invalid login parameter"))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(logMnt.BindError(err))
+		return
 	}
 
-	//连接数据库
-	db := data.ConnectDatabase()
-	if db == nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to connect to database"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to database"})
+	//从数据表获取用户信息，用户名不存在时storedUser为nil
+	storedUser, err := store.Users.FindByUsername(user.Username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		storedUser = nil
+	} else if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("log in: %w", err)))
 		return
 	}
+	userKey, ipKey := userSubject(storedUser, user.Username), ipSubject(c.ClientIP())
 
-	//从数据表获取用户信息
-	var storedUser data.User
-	if err := db.Where("username = ?", user.Username).First(&storedUser).Error; err != nil {
-		zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "Invalid username or password"))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+	//用户名或IP因连续登录失败被锁定时拒绝登录
+	wait, err := lockedFor(userKey, ipKey)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("log in: %w", err)))
 		return
 	}
-
-	//验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password)); err != nil {
-		zap.L().Error(logMnt.ErrUnauthorized.Message, zap.String("error", "Invalid username or password"))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+	if wait > 0 {
+		recordAttempt(c, storedUser, user.Username, reasonLocked)
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.Error(logMnt.ErrLoginLocked.With("retry_after", seconds).Wrap(fmt.Errorf("login locked for %q", user.Username)))
 		return
 	}
 
-	//创建JWT声明
-	claims := jwt.MapClaims{
-		"id":       storedUser.ID,
-		"username": storedUser.Username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), //24小时后过期
+	//验证密码，失败时同时计入用户名和IP的失败次数
+	if !checkPassword(storedUser, user.Password) {
+		recordAttempt(c, storedUser, user.Username, reasonPassword)
+		policy := loginPolicy()
+		if err := recordFailure(userKey, policy.MaxFailures); err != nil {
+			zap.L().Warn("failed to record login failure", zap.String("subject", userKey), zap.Error(err))
+		}
+		if err := recordFailure(ipKey, policy.IPMaxFailures); err != nil {
+			zap.L().Warn("failed to record login failure", zap.String("subject", ipKey), zap.Error(err))
+		}
+		c.Error(logMnt.ErrInvalidCredentials)
+		return
 	}
 
-	//生成JWT令牌
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	//签名令牌
-	tokenString, err := token.SignedString([]byte(cfg.CFG.Jwt.Secret))
+	//签发访问令牌和刷新令牌
+	pair, err := token.IssuePair(storedUser)
 	if err != nil {
-		zap.L().Error(logMnt.ErrInternalServerError.Message, zap.String("error", "Failed to generate token"))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("generate token: %w", err)))
+		return
 	}
 
+	//登录成功后重置该用户名的失败计数，IP的计数不受影响
+	if err := store.Logins.ClearFailure(userKey); err != nil {
+		zap.L().Warn("failed to clear login failures", zap.String("subject", userKey), zap.Error(err))
+	}
+	recordAttempt(c, storedUser, storedUser.Username, "")
+
+	role := ""
+	if storedUser.Role != nil {
+		role = storedUser.Role.Name
+	}
 	zap.L().Info("user login",
 		zap.Uint("user_id", storedUser.ID),
 		zap.String("username", storedUser.Username),
-		zap.String("token", tokenString),
 	)
 	//返回令牌给客户端
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User login successfully",
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"user": gin.H{
+			"id":             storedUser.ID,
+			"username":       storedUser.Username,
+			"role":           role,
+			"email_verified": storedUser.EmailVerifiedAt != nil,
+			"locale":         storedUser.Locale,
+		},
+		"token":           pair.AccessToken,
+		"expires":         pair.AccessExpiresAt.Unix(),
+		"refresh_token":   pair.RefreshToken,
+		"refresh_expires": pair.RefreshExpiresAt.Unix(),
+	})
+}
+
+// 用刷新令牌换取新的访问令牌和刷新令牌
+func Refresh(c *gin.Context) {
+	//获取刷新令牌
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	//轮换刷新令牌，重用已失效的刷新令牌会吊销整个登录会话
+	pair, err := token.Refresh(req.RefreshToken)
+	if errors.Is(err, token.ErrRefreshTokenReused) {
+		c.Error(logMnt.ErrRefreshTokenReused)
+		return
+	}
+	if errors.Is(err, token.ErrInvalidRefreshToken) {
+		c.Error(logMnt.ErrInvalidRefreshToken.Wrap(err))
+		return
+	}
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("refresh token: %w", err)))
+		return
+	}
+
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"token":           pair.AccessToken,
+		"expires":         pair.AccessExpiresAt.Unix(),
+		"refresh_token":   pair.RefreshToken,
+		"refresh_expires": pair.RefreshExpiresAt.Unix(),
+	})
+}
+
+// 退出登录，吊销当前访问令牌和同一登录会话的刷新令牌
+func Logout(c *gin.Context) {
+	value, _ := c.Get(claimsKey)
+	claims, ok := value.(*token.Claims)
+	if !ok {
+		c.Error(logMnt.ErrUnauthorized)
+		return
+	}
+
+	if err := token.Logout(claims); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("log out: %w", err)))
+		return
+	}
+
+	zap.L().Info("user logout", zap.Uint("user_id", claims.UserID))
+	logMnt.Success(c, http.StatusOK, nil)
+}
+
+// 当前用户最近的登录记录（包括失败的登录），用于发现账号异常登录
+func GetLogins(c *gin.Context) {
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		auth.RespondError(c, err)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(data.DefaultPageSize)))
+	if err != nil || limit < 1 {
+		c.Error(logMnt.InvalidField("limit", "min", "1"))
+		return
+	}
+	limit = min(limit, data.MaxPageSize)
+
+	attempts, err := store.Logins.ListAttempts(userID, limit)
+	if err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("get login history: %w", err)))
+		return
+	}
+
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"count": len(attempts),
+		"items": attempts,
+	})
+}
+
+// 修改用户角色，需要user:manage权限
+func UpdateRole(c *gin.Context) {
+	//获取目标用户和角色
+	var req struct {
+		UserID uint   `json:"user_id" binding:"required"`
+		Role   string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+
+	//查询角色
+	role, err := store.Roles.FindByName(req.Role)
+	if err != nil {
+		c.Error(logMnt.ErrRoleNotFound.Wrap(err))
+		return
+	}
+
+	//查询用户
+	storedUser, err := store.Users.FindByID(req.UserID)
+	if err != nil {
+		c.Error(logMnt.ErrUserNotFound.Wrap(err))
+		return
+	}
+
+	//更新角色，用户重新登录后新角色写入token
+	if err := store.Users.UpdateRole(storedUser, role); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update role: %w", err)))
+		return
+	}
+
+	zap.L().Info("update user role",
+		zap.Uint("user_id", storedUser.ID),
+		zap.String("role", role.Name),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
 		"user": gin.H{
 			"id":       storedUser.ID,
 			"username": storedUser.Username,
+			"role":     role.Name,
 		},
-		"token":   tokenString,
-		"expires": claims["exp"],
+	})
+}
+
+// 设置当前用户接口消息的语言偏好，locale为空时清除偏好、按Accept-Language选择语言；
+// 偏好保存在用户表中，本次响应立即使用，之后的每个请求都按新的偏好选择语言
+func UpdateLocale(c *gin.Context) {
+	userID, err := auth.CurrentUserID(c)
+	if err != nil {
+		auth.RespondError(c, err)
+		return
+	}
+
+	var req struct {
+		Locale *string `json:"locale" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(logMnt.BindError(err))
+		return
+	}
+	locale := i18n.Match(*req.Locale)
+	if locale == "" && *req.Locale != "" {
+		c.Error(logMnt.InvalidField("locale", "oneof", strings.Join(i18n.Locales, " ")))
+		return
+	}
+	logMnt.SetLocale(c, locale)
+
+	storedUser, err := store.Users.FindByID(userID)
+	if err != nil {
+		c.Error(logMnt.ErrUserNotFound.Wrap(err))
+		return
+	}
+	if err := store.Users.UpdateLocale(storedUser, locale); err != nil {
+		c.Error(logMnt.ErrInternalServerError.Wrap(fmt.Errorf("update locale: %w", err)))
+		return
+	}
+
+	zap.L().Info("update user locale",
+		zap.Uint("user_id", storedUser.ID),
+		zap.String("locale", locale),
+	)
+	logMnt.Success(c, http.StatusOK, gin.H{
+		"locale": storedUser.Locale,
 	})
 }